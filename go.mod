@@ -2,4 +2,4 @@ module blogo
 
 go 1.24.3
 
-require github.com/mattn/go-sqlite3 v1.14.28 // indirect
+require github.com/mattn/go-sqlite3 v1.14.28
@@ -0,0 +1,605 @@
+package cli
+
+import (
+	"blogo/internal/cron"
+	"blogo/internal/database"
+	"blogo/internal/hooks"
+	"blogo/internal/rss"
+	"blogo/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// fetchResult is what a fetch worker hands back for one feed.
+type fetchResult struct {
+	feed     database.FeedToFetch
+	rss      *rss.RSSFeed
+	status   int // HTTP status, 0 if no response was received
+	err      error
+	bytes    int64         // Size of the response body
+	duration time.Duration // Time spent on the request
+	// Set when the feed's host asked us to back off, or was already
+	// backing off; the feed should not be fetched before then.
+	deferredUntil time.Time
+	skipReason    string // Set when the feed was not fetched on purpose
+}
+
+// runSummary counts the outcome of one pass over the feeds.
+type runSummary struct {
+	Succeeded  int
+	Failed     int
+	Skipped    int
+	NewPosts   int
+	Duration   time.Duration
+	FailedURLs []string // Feeds that failed
+}
+
+func (r runSummary) String() string {
+	return fmt.Sprintf("%d succeeded, %d failed, %d skipped, %d new posts in %s",
+		r.Succeeded, r.Failed, r.Skipped, r.NewPosts, r.Duration.Round(time.Millisecond))
+}
+
+// aggregator fetches feeds on their adaptive schedule and stores new posts.
+type aggregator struct {
+	s           *State
+	workers     int
+	minInterval time.Duration
+	maxInterval time.Duration
+	maxFailures int // consecutive failures before a feed is disabled
+	hosts       *hostLimiter
+	robots      *rss.RobotsChecker // nil when robots.txt is ignored
+	historyKeep time.Duration      // how long fetch history is kept
+	retention   database.Retention // global post retention limits
+	lastPrune   time.Time          // when posts were last pruned
+	metrics     *aggMetrics
+	hooks       *hooks.Runner
+	schedule    *cron.Schedule // when to look for due feeds; nil to do so continuously
+	jitter      time.Duration  // maximum random delay of scheduled runs
+}
+
+func newAggregator(s *State) *aggregator {
+	var robots *rss.RobotsChecker
+	if !s.Cfg.IgnoreRobots {
+		robots = rss.NewRobotsChecker(s.Cfg.RobotsCacheTTL.Duration)
+	}
+	a := &aggregator{
+		s:           s,
+		workers:     s.Cfg.FetchWorkers,
+		minInterval: s.Cfg.MinPollInterval.Duration,
+		maxInterval: s.Cfg.MaxPollInterval.Duration,
+		maxFailures: s.Cfg.MaxFeedFailures,
+		hosts:       newHostLimiter(s.Cfg.HostConcurrency, s.Cfg.HostMinInterval.Duration),
+		robots:      robots,
+		historyKeep: time.Duration(s.Cfg.HistoryDays) * 24 * time.Hour,
+		retention:   database.Retention{Days: s.Cfg.RetainDays, Items: s.Cfg.RetainItems},
+		jitter:      s.Cfg.ScheduleJitter.Duration,
+	}
+	a.metrics = newAggMetrics(a)
+	a.hooks = newHookRunner(s)
+	return a
+}
+
+// newHookRunner returns a runner for the hooks in the config.
+func newHookRunner(s *State) *hooks.Runner {
+	var hs []hooks.Hook
+	for i, h := range s.Cfg.Hooks {
+		name := h.Name
+		if name == "" {
+			name = fmt.Sprintf("hook %d", i+1)
+		}
+		hs = append(hs, hooks.Hook{
+			Name:     name,
+			Command:  h.Command,
+			Feeds:    h.Feeds,
+			Keywords: h.Keywords,
+			Batch:    h.Batch,
+			Timeout:  h.Timeout.Duration,
+		})
+	}
+	return hooks.NewRunner(hs, s.Cfg.HookConcurrency, s.Log)
+}
+
+// run fetches feeds as they come due until ctx ends. With a cron schedule,
+// it only looks for due feeds when the schedule fires.
+func (a *aggregator) run(ctx context.Context) {
+	if a.schedule != nil {
+		a.runScheduled(ctx)
+		return
+	}
+	for ctx.Err() == nil {
+		a.pass(ctx)
+		select {
+		case <-ctx.Done():
+		case <-time.After(a.idleTime()):
+		}
+	}
+}
+
+// runScheduled runs a pass each time the cron schedule fires, delayed by a
+// random jitter. Each pass is cut short when the schedule next fires, so
+// passes never overlap.
+func (a *aggregator) runScheduled(ctx context.Context) {
+	for ctx.Err() == nil {
+		next := a.schedule.Next(time.Now())
+		if next.IsZero() {
+			a.s.Log.Error("cron schedule never fires", "schedule", a.schedule.String())
+			return
+		}
+		after := a.schedule.Next(next)
+		delay := time.Until(next) + a.jitterFor(after.Sub(next))
+		a.s.Log.Info("waiting for next scheduled run", "at", next, "delay", delay.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		runCtx, cancel := context.WithDeadline(ctx, after)
+		a.pass(runCtx)
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			a.s.Log.Warn("run cut short by the next scheduled run", "deadline", after)
+		}
+		cancel()
+	}
+}
+
+// jitterFor returns a random delay of up to the configured jitter, but at
+// most a quarter of gap so a run keeps most of its slot.
+func (a *aggregator) jitterFor(gap time.Duration) time.Duration {
+	limit := a.jitter
+	if gap > 0 {
+		limit = min(limit, gap/4)
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit)
+}
+
+// pass fetches the feeds that are currently due, then sends any digests that
+// have come due.
+func (a *aggregator) pass(ctx context.Context) {
+	due, err := database.GetDueFeeds(a.s.DB, 0)
+	if err != nil {
+		a.s.Log.Error("could not list due feeds", "err", err)
+		return
+	}
+	if len(due) > 0 {
+		sum := a.fetchAll(ctx, due)
+		a.s.Log.Info("run finished",
+			"succeeded", sum.Succeeded, "failed", sum.Failed, "skipped", sum.Skipped,
+			"new_posts", sum.NewPosts, "duration", sum.Duration.Round(time.Millisecond))
+		a.pruneHistory()
+		a.prunePosts()
+	} else {
+		a.deliverWebhooks(ctx) // retries may have come due
+	}
+	if a.s.Cfg.SMTP.Host != "" && ctx.Err() == nil {
+		if _, _, err := sendDigests(a.s, false, false); err != nil {
+			a.s.Log.Error("could not send digests", "err", err)
+		}
+	}
+}
+
+// pruneHistory deletes fetch history and finished webhook deliveries older
+// than the configured number of days.
+func (a *aggregator) pruneHistory() {
+	if _, err := database.PruneFetchHistory(a.s.DB, a.historyKeep); err != nil {
+		a.s.Log.Error("could not prune fetch history", "err", err)
+	}
+	if _, err := database.PruneWebhookDeliveries(a.s.DB, a.historyKeep); err != nil {
+		a.s.Log.Error("could not prune webhook deliveries", "err", err)
+	}
+}
+
+// postPruneInterval is how often a long-running aggregator prunes posts
+// past their retention.
+const postPruneInterval = time.Hour
+
+// prunePosts deletes posts past their retention, at most once per
+// postPruneInterval, then hands the freed space back to the filesystem.
+func (a *aggregator) prunePosts() {
+	if time.Since(a.lastPrune) < postPruneInterval {
+		return
+	}
+	a.lastPrune = time.Now()
+	pruned, err := database.PrunePosts(a.s.DB, a.retention, false)
+	if err != nil {
+		a.s.Log.Error("could not prune posts", "err", err)
+		return
+	}
+	var total int64
+	for _, pf := range pruned {
+		total += pf.Posts
+		a.s.Log.Debug("pruned posts", "feed_id", pf.FeedID, "url", pf.URL, "posts", pf.Posts)
+	}
+	if total == 0 {
+		return
+	}
+	a.s.Log.Info("pruned posts past retention", "posts", total, "feeds", len(pruned))
+	if err := database.IncrementalVacuum(a.s.DB); err != nil {
+		a.s.Log.Error("could not vacuum database", "err", err)
+	}
+}
+
+// idleTime returns how long to wait for the next feed to come due. It never
+// exceeds the minimum interval, so newly added feeds are picked up promptly.
+func (a *aggregator) idleTime() time.Duration {
+	wait := a.minInterval
+	next, err := database.GetNextFeedToFetch(a.s.DB)
+	if err != nil {
+		a.s.Log.Error("could not find next feed", "err", err)
+		return wait
+	}
+	if next != nil && next.NextFetchAt.Valid {
+		wait = min(wait, time.Until(next.NextFetchAt.Time))
+	}
+	return max(wait, time.Second)
+}
+
+// fetchAll fetches the given feeds once and stores their new posts.
+//
+// Fetching happens in a pool of workers; everything that touches the
+// database runs on the calling goroutine so SQLite only ever sees one writer.
+// When ctx ends, no further feeds are started and in-flight fetches are
+// abandoned, but posts from fetches that already finished are still stored.
+//
+// The run and every attempted feed are recorded in the fetch history. New
+// posts are queued for webhooks, and due webhook deliveries sent, once all
+// feeds are stored; hooks started for new posts have finished by the time
+// fetchAll returns.
+func (a *aggregator) fetchAll(ctx context.Context, feeds []database.FeedToFetch) runSummary {
+	start := time.Now()
+	var sum runSummary
+	var runID sql.NullInt64
+	if id, err := database.StartFetchRun(a.s.DB, len(feeds)); err != nil {
+		a.s.Log.Error("could not record fetch run", "err", err)
+	} else {
+		runID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	jobs := make(chan database.FeedToFetch)
+	results := make(chan fetchResult)
+	var wg sync.WaitGroup
+	for range max(a.workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ff := range jobs {
+				results <- a.fetchPolitely(ctx, ff)
+			}
+		}()
+	}
+
+	var valid []database.FeedToFetch
+	for _, ff := range feeds {
+		if u, err := url.Parse(ff.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			a.feedLog(ff).Warn("skipping feed", "reason", "not an http(s) URL")
+			a.logFetch(runID, fetchResult{feed: ff, skipReason: "not an http(s) URL"}, database.FetchSkipped, 0)
+			a.reschedule(ff.ID, a.maxInterval)
+			sum.Skipped++
+			continue
+		}
+		valid = append(valid, ff)
+	}
+	go func() {
+	dispatch:
+		for _, ff := range interleaveHosts(valid) {
+			select {
+			case jobs <- ff:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	handled := 0
+	for res := range results {
+		handled++
+		if ctx.Err() != nil && errors.Is(res.err, ctx.Err()) {
+			// Interrupted by shutdown; the feed stays due for next time.
+			sum.Skipped++
+			continue
+		}
+		a.metrics.observeFetch(res)
+		writeStart := time.Now()
+		a.store(runID, res, &sum)
+		a.metrics.dbWrite.Observe(time.Since(writeStart).Seconds())
+	}
+
+	sum.Skipped += len(valid) - handled // never started because of shutdown
+	sum.Duration = time.Since(start)
+	if runID.Valid {
+		err := database.FinishFetchRun(a.s.DB, database.FetchRun{
+			ID:        runID.Int64,
+			Succeeded: sum.Succeeded,
+			Failed:    sum.Failed,
+			Skipped:   sum.Skipped,
+			NewPosts:  sum.NewPosts,
+		})
+		if err != nil {
+			a.s.Log.Error("could not record fetch run", "err", err)
+		}
+	}
+	a.deliverWebhooks(ctx)
+	a.hooks.Wait()
+	return sum
+}
+
+// store records the outcome of one fetch, saves its posts and schedules the
+// feed's next fetch, counting the outcome in sum.
+func (a *aggregator) store(runID sql.NullInt64, res fetchResult, sum *runSummary) {
+	log := a.feedLog(res.feed)
+	if !res.deferredUntil.IsZero() {
+		log.Info("deferring feed, host asked us to back off", "until", res.deferredUntil)
+		if err := database.DeferFeed(a.s.DB, res.feed.ID, res.deferredUntil); err != nil {
+			log.Error("could not defer feed", "err", err)
+		}
+		a.logFetch(runID, res, database.FetchDeferred, 0)
+		sum.Skipped++
+		return
+	}
+	if res.skipReason != "" {
+		log.Warn("skipping feed", "reason", res.skipReason)
+		if err := database.RecordFetchSkipped(a.s.DB, res.feed.ID, res.skipReason); err != nil {
+			log.Error("could not record skipped fetch", "err", err)
+		}
+		a.logFetch(runID, res, database.FetchSkipped, 0)
+		a.reschedule(res.feed.ID, a.maxInterval)
+		sum.Skipped++
+		return
+	}
+	if err := database.MarkFeedFetched(a.s.DB, res.feed.ID); err != nil {
+		log.Error("could not mark feed fetched", "err", err)
+	}
+	if res.err != nil {
+		a.logFetch(runID, res, database.FetchFailed, 0)
+		a.recordFailure(res)
+		sum.Failed++
+		sum.FailedURLs = append(sum.FailedURLs, res.feed.URL)
+		return
+	}
+	saved, err := ingestItems(a.s, log, res.feed.ID, res.rss.Channel.Items)
+	if err != nil {
+		// The feed is fine, so this doesn't count towards disabling it;
+		// nothing was stored, so try again soon.
+		log.Error("could not save posts", "err", err)
+		res.err = err
+		a.logFetch(runID, res, database.FetchFailed, 0)
+		a.reschedule(res.feed.ID, a.minInterval)
+		sum.Failed++
+		sum.FailedURLs = append(sum.FailedURLs, res.feed.URL)
+		return
+	}
+	if err := database.RecordFetchSuccess(a.s.DB, res.feed.ID, res.status); err != nil {
+		log.Error("could not record successful fetch", "err", err)
+	}
+	if _, err := database.ForgetPrunedPosts(a.s.DB, res.feed.ID, itemURLs(res.rss.Channel.Items)); err != nil {
+		log.Error("could not forget pruned posts", "err", err)
+	}
+	inserted := len(saved.Inserted)
+	log.Info("fetched feed", "title", res.rss.Channel.Title, "status", res.status,
+		"bytes", res.bytes, "duration", res.duration.Round(time.Millisecond),
+		"items", len(res.rss.Channel.Items), "new_posts", inserted)
+	a.metrics.postsInserted.Add(float64(inserted))
+	a.logFetch(runID, res, database.FetchOK, inserted)
+	sum.NewPosts += inserted
+	sum.Succeeded++
+	a.reschedule(res.feed.ID, a.nextInterval(res.feed.ID))
+	if inserted > 0 {
+		a.queueWebhooks(res.feed.ID, saved.Inserted)
+		a.hooks.Dispatch(a.s.Ctx, hookPosts(res, saved.Inserted))
+	}
+}
+
+// hookPosts describes newly stored posts for hooks.
+func hookPosts(res fetchResult, posts []*database.Post) []hooks.Post {
+	out := make([]hooks.Post, 0, len(posts))
+	for _, p := range posts {
+		hp := hooks.Post{
+			ID:          p.ID,
+			Title:       p.Title,
+			URL:         p.URL,
+			Description: p.Description.String,
+			FeedID:      res.feed.ID,
+			FeedURL:     res.feed.URL,
+			FeedTitle:   res.rss.Channel.Title,
+		}
+		if p.PublishedAt.Valid {
+			hp.PublishedAt = &p.PublishedAt.Time
+		}
+		out = append(out, hp)
+	}
+	return out
+}
+
+// feedLog returns the logger for messages about one feed.
+func (a *aggregator) feedLog(ff database.FeedToFetch) *slog.Logger {
+	return a.s.Log.With("feed_id", ff.ID, "url", ff.URL)
+}
+
+// logFetch records the outcome of one feed in the fetch history.
+func (a *aggregator) logFetch(runID sql.NullInt64, res fetchResult, outcome string, inserted int) {
+	e := database.FetchLogEntry{
+		RunID:         runID,
+		FeedID:        res.feed.ID,
+		Outcome:       outcome,
+		Status:        sql.NullInt64{Int64: int64(res.status), Valid: res.status != 0},
+		Bytes:         res.bytes,
+		Duration:      res.duration,
+		ItemsInserted: inserted,
+	}
+	if res.rss != nil {
+		e.ItemsSeen = len(res.rss.Channel.Items)
+	}
+	switch {
+	case res.err != nil:
+		e.Error = sql.NullString{String: res.err.Error(), Valid: true}
+	case res.skipReason != "":
+		e.Error = sql.NullString{String: res.skipReason, Valid: true}
+	case !res.deferredUntil.IsZero():
+		e.Error = sql.NullString{
+			String: "deferred until " + res.deferredUntil.Local().Format(time.DateTime),
+			Valid:  true,
+		}
+	}
+	if err := database.LogFetch(a.s.DB, e); err != nil {
+		a.feedLog(res.feed).Error("could not record fetch", "err", err)
+	}
+}
+
+// fetchPolitely fetches a feed within its host's limits and robots.txt. If
+// the host is backing off, or answers 429/503 with Retry-After, the feed is
+// deferred instead and the host is left alone until the deadline.
+func (a *aggregator) fetchPolitely(ctx context.Context, ff database.FeedToFetch) fetchResult {
+	a.feedLog(ff).Debug("fetching feed")
+	host := hostOf(ff.URL)
+	if ok, until := a.hosts.acquire(ctx, host); !ok {
+		if until.IsZero() {
+			return fetchResult{feed: ff, err: ctx.Err()}
+		}
+		return fetchResult{feed: ff, deferredUntil: until}
+	}
+	defer a.hosts.release(host)
+	if a.robots != nil {
+		// robots.txt requests count against the host's limits too: the slot
+		// taken above covers one, and the feed request waits its turn after
+		// it.
+		ok, fetched, err := a.robots.Allowed(ctx, ff.URL)
+		if ctx.Err() != nil {
+			return fetchResult{feed: ff, err: ctx.Err()}
+		}
+		if err != nil || !ok {
+			return fetchResult{feed: ff, skipReason: "disallowed by robots.txt"}
+		}
+		if fetched && !a.hosts.respace(ctx, host) {
+			return fetchResult{feed: ff, err: ctx.Err()}
+		}
+	}
+	res := fetchOne(ctx, ff)
+
+	var se *rss.StatusError
+	if errors.As(res.err, &se) && se.RetryAfter > 0 {
+		res.deferredUntil = time.Now().Add(se.RetryAfter)
+		a.hosts.deferHost(host, res.deferredUntil)
+	}
+	return res
+}
+
+// fetchOne downloads and parses a single feed.
+func fetchOne(ctx context.Context, ff database.FeedToFetch) fetchResult {
+	res := fetchResult{feed: ff}
+	start := time.Now()
+	resp, err := rss.Fetch(ctx, ff.URL)
+	res.duration = time.Since(start)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.status = resp.StatusCode
+	res.bytes = int64(len(resp.Body))
+	res.rss, res.err = resp.Feed()
+	return res
+}
+
+// recordFailure stores a failed fetch on the feed and backs off
+// exponentially, disabling the feed once it has failed too often in a row.
+func (a *aggregator) recordFailure(res fetchResult) {
+	log := a.feedLog(res.feed)
+	failures, err := database.RecordFetchFailure(a.s.DB, res.feed.ID, res.status, res.err.Error())
+	if err != nil {
+		log.Error("could not record failed fetch", "err", err)
+		a.reschedule(res.feed.ID, a.minInterval)
+		return
+	}
+	if failures >= a.maxFailures {
+		log.Error("disabling feed after too many consecutive failures", "failures", failures, "err", res.err)
+		if err := database.DisableFeed(a.s.DB, res.feed.ID); err != nil {
+			log.Error("could not disable feed", "err", err)
+		}
+		return
+	}
+	log.Warn("failed to fetch feed", "failures", failures, "status", res.status,
+		"duration", res.duration.Round(time.Millisecond), "err", res.err)
+	a.reschedule(res.feed.ID, backoffInterval(failures, a.minInterval, a.maxInterval))
+}
+
+// nextInterval estimates how long to wait before fetching the feed again
+// from its posting history.
+func (a *aggregator) nextInterval(feedID int64) time.Duration {
+	published, err := database.GetRecentPublishTimes(a.s.DB, feedID, recentPostsForSchedule)
+	if err != nil {
+		a.s.Log.Error("could not load publish times", "feed_id", feedID, "err", err)
+		return a.minInterval
+	}
+	return pollInterval(published, time.Now(), a.minInterval, a.maxInterval)
+}
+
+func (a *aggregator) reschedule(feedID int64, interval time.Duration) {
+	if err := database.ScheduleFeed(a.s.DB, feedID, interval); err != nil {
+		a.s.Log.Error("could not schedule feed", "feed_id", feedID, "err", err)
+	}
+}
+
+// ingestItems saves a feed's items as posts in one transaction, logging
+// edited posts to log. If saving fails, nothing is stored.
+func ingestItems(s *State, log *slog.Logger, feedID int64, items []rss.RSSItem) (database.IngestResult, error) {
+	posts := make([]*database.Post, 0, len(items))
+	for _, item := range items {
+		posts = append(posts, itemToPost(log, item, feedID))
+	}
+	res, err := database.IngestPosts(s.DB, posts)
+	if err != nil {
+		return res, err
+	}
+	for _, p := range res.Updated {
+		log.Info("post edited", "title", p.Title, "post_url", p.URL)
+	}
+	log.Debug("saved posts", "inserted", len(res.Inserted), "updated", len(res.Updated), "skipped", res.Skipped)
+	return res, nil
+}
+
+// itemURLs returns the links of a feed's items.
+func itemURLs(items []rss.RSSItem) []string {
+	urls := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Link != "" {
+			urls = append(urls, item.Link)
+		}
+	}
+	return urls
+}
+
+// itemToPost converts a parsed feed item into a post for the given feed.
+//
+// Unparseable dates are logged and left unset.
+func itemToPost(log *slog.Logger, item rss.RSSItem, feedID int64) *database.Post {
+	pub, err := utils.ParsePubDate(item.PubDate)
+	if err != nil {
+		log.Warn("could not parse date", "post_url", item.Link, "date", item.PubDate, "err", err)
+	}
+	var updated time.Time
+	if item.Updated != "" {
+		if updated, err = utils.ParsePubDate(item.Updated); err != nil {
+			log.Warn("could not parse date", "post_url", item.Link, "date", item.Updated, "err", err)
+		}
+	}
+	return &database.Post{
+		Title:           item.Title,
+		URL:             item.Link,
+		Description:     sql.NullString{String: item.Description, Valid: item.Description != ""},
+		PublishedAt:     sql.NullTime{Time: pub, Valid: !pub.IsZero()},
+		SourceUpdatedAt: sql.NullTime{Time: updated, Valid: !updated.IsZero()},
+		FeedID:          feedID,
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"blogo/internal/rss"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestState returns a State with a new, migrated database in a temporary
+// directory and a logger that discards everything.
+func newTestState(t *testing.T) *State {
+	t.Helper()
+	return &State{
+		DB:  database.OpenMigratedTestDB(t),
+		Ctx: context.Background(),
+		Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// TestFetchPolitelySpacesRobots checks that fetching robots.txt counts as a
+// request to the host, so the feed request waits out the spacing after it.
+func TestFetchPolitelySpacesRobots(t *testing.T) {
+	const spacing = 200 * time.Millisecond
+	var mu sync.Mutex
+	seen := map[string]time.Time{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = time.Now()
+		mu.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			io.WriteString(w, "User-agent: *\nDisallow: /private/\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, `<rss version="2.0"><channel><title>Test</title></channel></rss>`)
+	}))
+	defer srv.Close()
+
+	a := &aggregator{
+		s:      &State{Log: slog.New(slog.NewTextHandler(io.Discard, nil))},
+		hosts:  newHostLimiter(1, spacing),
+		robots: rss.NewRobotsChecker(time.Hour),
+	}
+	res := a.fetchPolitely(context.Background(), database.FeedToFetch{ID: 1, URL: srv.URL + "/feed.xml"})
+	if res.err != nil || res.skipReason != "" {
+		t.Fatalf("fetchPolitely: err %v, skipped %q", res.err, res.skipReason)
+	}
+
+	mu.Lock()
+	robots, feed := seen["/robots.txt"], seen["/feed.xml"]
+	mu.Unlock()
+	if robots.IsZero() || feed.IsZero() {
+		t.Fatalf("requests seen: %v", seen)
+	}
+	// The spacing runs from when the slot was taken, just before robots.txt
+	// was requested, so allow for the time that took.
+	if gap := feed.Sub(robots); gap < spacing-20*time.Millisecond {
+		t.Errorf("feed requested %v after robots.txt, want at least %v", gap, spacing)
+	}
+}
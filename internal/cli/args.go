@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseFlags separates --name options from positional arguments.
+//
+// spec lists the accepted option names; a true value means the option takes
+// a value, given either as --name=value or as the following argument.
+// Boolean options map to "true". Everything after a bare "--" is positional.
+func parseFlags(args []string, spec map[string]bool) ([]string, map[string]string, error) {
+	var pos []string
+	opts := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			pos = append(pos, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			pos = append(pos, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		takesValue, ok := spec[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown option --%s", name)
+		}
+		switch {
+		case !takesValue && hasValue:
+			return nil, nil, fmt.Errorf("option --%s does not take a value", name)
+		case !takesValue:
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("option --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		opts[name] = value
+	}
+	return pos, opts, nil
+}
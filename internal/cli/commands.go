@@ -3,14 +3,18 @@ package cli
 import (
 	"blogo/internal/config"
 	"blogo/internal/database"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 )
 
 type State struct {
 	Cfg *config.Config
 	DB  *sql.DB
+	Ctx context.Context // Cancelled when the user asks blogo to stop
+	Log *slog.Logger    // Diagnostics; command output goes to stdout
 }
 
 type Command struct {
@@ -41,8 +45,7 @@ func MiddlewareLoggedIn(handler func(s *State, cmd Command, user database.User)
 	return func(s *State, cmd Command) error {
 		username := s.Cfg.CurrentUser
 		if username == "" {
-			fmt.Printf("%s: you must login first (try `login <username>`)\n", cmd.Name)
-			return nil
+			return fmt.Errorf("%s: you must login first (try `login <username>`)", cmd.Name)
 		}
 
 		uid, err := database.GetUserID(s.DB, username)
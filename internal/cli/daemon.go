@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aggLockName is the lease that allows one aggregator per database.
+const aggLockName = "aggregator"
+
+// aggLockTTL is how long the lease survives without renewal, i.e. how long
+// a crashed aggregator on another machine blocks a new one.
+const aggLockTTL = time.Minute
+
+// daemonStartTimeout is how long `agg --daemon` waits for the background
+// aggregator to take the lease before giving up on it.
+const daemonStartTimeout = 10 * time.Second
+
+// instanceLock is this process's hold on a database lease.
+type instanceLock struct {
+	s     *State
+	name  string
+	owner string
+}
+
+// acquireLock takes the named lease for this process. A lease left behind by
+// a process on this host that no longer exists is taken over; a live holder
+// is reported as an error.
+func acquireLock(s *State, name string) (*instanceLock, error) {
+	host, _ := os.Hostname()
+	token := make([]byte, 8)
+	rand.Read(token)
+	l := &instanceLock{s: s, name: name, owner: hex.EncodeToString(token)}
+
+	for attempt := 0; ; attempt++ {
+		ok, holder, err := database.AcquireLock(s.DB, name, l.owner, os.Getpid(), host, aggLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return l, nil
+		}
+		if holder == nil {
+			continue // expired between the two queries
+		}
+		if attempt == 0 && holder.Hostname == host && !processAlive(holder.PID) {
+			s.Log.Warn("removing stale lock", "lock", name, "pid", holder.PID)
+			if err := database.ReleaseLock(s.DB, name, holder.Owner); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, fmt.Errorf("already running as pid %d on %s since %s",
+			holder.PID, holder.Hostname, holder.AcquiredAt.Local().Format(time.DateTime))
+	}
+}
+
+// keepAlive renews the lease until ctx ends. If the lease is lost, lost is
+// called so the holder can stop before another instance starts writing.
+func (l *instanceLock) keepAlive(ctx context.Context, lost func()) {
+	tick := time.NewTicker(aggLockTTL / 3)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := database.RenewLock(l.s.DB, l.name, l.owner, aggLockTTL); err != nil {
+				l.s.Log.Error("lost instance lock", "lock", l.name, "err", err)
+				lost()
+				return
+			}
+		}
+	}
+}
+
+func (l *instanceLock) release() {
+	if err := database.ReleaseLock(l.s.DB, l.name, l.owner); err != nil {
+		l.s.Log.Error("could not release instance lock", "lock", l.name, "err", err)
+	}
+}
+
+// runLocked runs fn while holding the aggregator lease and the PID file,
+// stopping it early if the lease is lost.
+func runLocked(s *State, fn func(ctx context.Context) error) error {
+	lock, err := acquireLock(s, aggLockName)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	pidFile := s.Cfg.AggPIDFile
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		s.Log.Warn("could not write pid file", "path", pidFile, "err", err)
+	} else {
+		defer os.Remove(pidFile)
+	}
+
+	ctx, cancel := context.WithCancel(s.Ctx)
+	defer cancel()
+	go lock.keepAlive(ctx, cancel)
+	return fn(ctx)
+}
+
+// startDaemon runs blogo with args in the background, detached from the
+// terminal with its output appended to the configured log file, and waits
+// for it to take the aggregator lease.
+func startDaemon(s *State, args []string) error {
+	if holder, err := database.GetLock(s.DB, aggLockName); err != nil {
+		return err
+	} else if holder != nil {
+		return fmt.Errorf("already running as pid %d on %s", holder.PID, holder.Hostname)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logPath := s.Cfg.AggLogFile
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	if err := startDetached(cmd); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(daemonStartTimeout)
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("background aggregator exited during startup (%v), see %s", err, logPath)
+		case <-deadline:
+			return fmt.Errorf("background aggregator (pid %d) did not start within %s, see %s",
+				cmd.Process.Pid, daemonStartTimeout, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+		holder, err := database.GetLock(s.DB, aggLockName)
+		if err != nil {
+			return err
+		}
+		if holder != nil && holder.PID == cmd.Process.Pid {
+			fmt.Printf("Aggregator started in the background (pid %d), logging to %s\n", cmd.Process.Pid, logPath)
+			return nil
+		}
+	}
+}
+
+// withoutFlag returns args minus every occurrence of the boolean --name.
+func withoutFlag(args []string, name string) []string {
+	var out []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(out, args[i:]...)
+		}
+		if arg != "--"+name {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+// aggStatus reports the aggregator holding the lease, if any.
+func aggStatus(s *State) error {
+	holder, err := database.GetLock(s.DB, aggLockName)
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		fmt.Println("Aggregator is not running.")
+		if pid, err := readPIDFile(s.Cfg.AggPIDFile); err == nil && processAlive(pid) {
+			fmt.Printf("warning: %s names pid %d, which is running but holds no lock\n", s.Cfg.AggPIDFile, pid)
+		}
+		return nil
+	}
+	fmt.Printf("Aggregator is running as pid %d on %s\n", holder.PID, holder.Hostname)
+	fmt.Printf("  started:     %s\n", holder.AcquiredAt.Local().Format(time.DateTime))
+	fmt.Printf("  lease until: %s\n", holder.ExpiresAt.Local().Format(time.DateTime))
+	if host, _ := os.Hostname(); holder.Hostname == host && !processAlive(holder.PID) {
+		fmt.Println("  warning: that process no longer exists; the lock is stale")
+	}
+	return nil
+}
+
+// aggStop asks the aggregator holding the lease to shut down and waits for
+// it to release the lease.
+func aggStop(s *State) error {
+	holder, err := database.GetLock(s.DB, aggLockName)
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		return errors.New("aggregator is not running")
+	}
+	if host, _ := os.Hostname(); holder.Hostname != host {
+		return fmt.Errorf("aggregator is running on %s (pid %d), stop it there", holder.Hostname, holder.PID)
+	}
+	if !processAlive(holder.PID) {
+		fmt.Printf("pid %d no longer exists, removing its stale lock\n", holder.PID)
+		return database.ReleaseLock(s.DB, aggLockName, holder.Owner)
+	}
+	if err := stopProcess(holder.PID); err != nil {
+		return fmt.Errorf("signal pid %d: %w", holder.PID, err)
+	}
+	fmt.Printf("Stopping aggregator (pid %d)...\n", holder.PID)
+
+	deadline := time.Now().Add(aggLockTTL)
+	for time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+		current, err := database.GetLock(s.DB, aggLockName)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Owner != holder.Owner {
+			fmt.Println("Aggregator stopped.")
+			return nil
+		}
+	}
+	return fmt.Errorf("pid %d still holds the lock after %s", holder.PID, aggLockTTL)
+}
+
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func startDetached(cmd *exec.Cmd) error {
+	return errors.New("--daemon is only supported on Unix systems")
+}
+
+// processAlive cannot be checked portably, so every process is assumed to
+// be alive and stale locks simply wait for their lease to expire.
+func processAlive(pid int) bool {
+	return true
+}
+
+// stopProcess kills the process, since there is no portable way to ask it to
+// shut down gracefully.
+func stopProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
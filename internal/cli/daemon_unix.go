@@ -0,0 +1,26 @@
+//go:build unix
+
+package cli
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// startDetached starts cmd in a new session so it outlives the terminal.
+func startDetached(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return cmd.Start()
+}
+
+// processAlive reports whether a process with the given pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// stopProcess asks the process to shut down gracefully.
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"blogo/internal/digest"
+	"blogo/internal/mail"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const digestUsage = "digest | digest email <address|none> | digest every <daily|weekly|off> | digest send [--all] [--dry-run]"
+
+// HandlerDigest shows or changes the current user's digest settings, or
+// sends the digests that are due.
+func HandlerDigest(s *State, cmd Command) error {
+	if len(cmd.Args) == 0 {
+		return MiddlewareLoggedIn(digestShow)(s, cmd)
+	}
+	sub := Command{Name: cmd.Name + " " + cmd.Args[0], Args: cmd.Args[1:]}
+	switch cmd.Args[0] {
+	case "email":
+		return MiddlewareLoggedIn(digestEmail)(s, sub)
+	case "every":
+		return MiddlewareLoggedIn(digestEvery)(s, sub)
+	case "send":
+		return digestSend(s, sub)
+	}
+	return fmt.Errorf("%s: usage: %s", cmd.Name, digestUsage)
+}
+
+func digestShow(s *State, cmd Command, user database.User) error {
+	d, err := database.GetDigestSubscriber(s.DB, user.ID)
+	if err != nil {
+		return err
+	}
+	email := "(none, set one with `digest email <address>`)"
+	if d.Email.Valid {
+		email = d.Email.String
+	}
+	fmt.Printf("Email: %s\n", email)
+	if !d.Frequency.Valid {
+		fmt.Println("Digests: off")
+		return nil
+	}
+	fmt.Printf("Digests: %s", d.Frequency.String)
+	if d.LastDigestAt.Valid {
+		fmt.Printf(", next one covers posts stored after %s", d.LastDigestAt.Time.Local().Format(time.DateTime))
+	}
+	fmt.Println()
+	if s.Cfg.SMTP.Host == "" {
+		fmt.Println("Note: no SMTP server is configured, so digests are not being sent.")
+	}
+	return nil
+}
+
+func digestEmail(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: digest email <address|none>", cmd.Name)
+	}
+	var email sql.NullString
+	if cmd.Args[0] != "none" {
+		if !strings.Contains(cmd.Args[0], "@") || strings.ContainsAny(cmd.Args[0], " <>\r\n") {
+			return fmt.Errorf("%s: %q is not an email address", cmd.Name, cmd.Args[0])
+		}
+		email = sql.NullString{String: cmd.Args[0], Valid: true}
+	}
+	if err := database.SetUserEmail(s.DB, user.ID, email); err != nil {
+		return err
+	}
+	if email.Valid {
+		fmt.Printf("Email set to %s.\n", email.String)
+	} else {
+		fmt.Println("Email removed; you will not get digests.")
+	}
+	return nil
+}
+
+func digestEvery(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: digest every <daily|weekly|off>", cmd.Name)
+	}
+	var frequency sql.NullString
+	switch cmd.Args[0] {
+	case database.DigestDaily, database.DigestWeekly:
+		frequency = sql.NullString{String: cmd.Args[0], Valid: true}
+	case "off":
+	default:
+		return fmt.Errorf("%s: usage: digest every <daily|weekly|off>", cmd.Name)
+	}
+	if err := database.SetDigestFrequency(s.DB, user.ID, frequency); err != nil {
+		return err
+	}
+	if !frequency.Valid {
+		fmt.Println("Digests turned off.")
+		return nil
+	}
+	fmt.Printf("You will get a %s digest of new posts in the feeds you follow.\n", frequency.String)
+	if d, err := database.GetDigestSubscriber(s.DB, user.ID); err == nil && !d.Email.Valid {
+		fmt.Println("Set an address to send it to with `digest email <address>`.")
+	}
+	return nil
+}
+
+func digestSend(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"all": false, "dry-run": false})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("%s: usage: digest send [--all] [--dry-run]", cmd.Name)
+	}
+	dryRun := opts["dry-run"] != ""
+	if !dryRun && s.Cfg.SMTP.Host == "" {
+		return fmt.Errorf("%s: no SMTP server configured (set smtp.host in the config)", cmd.Name)
+	}
+	// Sending holds the aggregator lock, like fetch, so two processes never
+	// send the same digest.
+	var sent int
+	var failed []string
+	send := func(context.Context) error {
+		sent, failed, err = sendDigests(s, opts["all"] != "", dryRun)
+		return err
+	}
+	if dryRun {
+		err = send(s.Ctx)
+	} else {
+		err = runLocked(s, send)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if !dryRun {
+		fmt.Printf("Sent %d digest(s).\n", sent)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s: could not send digests to %s", cmd.Name, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// sendDigests emails each subscriber whose digest is due (or every
+// subscriber, with all set) the posts stored since their last one. Users
+// with nothing new get no email, but are marked as sent so the next digest
+// starts from now. With dryRun set, digests are printed instead of sent and
+// nothing is marked.
+//
+// Returns how many digests were sent, and the users whose digest failed.
+func sendDigests(s *State, all, dryRun bool) (int, []string, error) {
+	subs, err := database.GetDigestSubscribers(s.DB, !all)
+	if err != nil {
+		return 0, nil, err
+	}
+	srv := mail.Server{
+		Host:     s.Cfg.SMTP.Host,
+		Port:     s.Cfg.SMTP.Port,
+		Username: s.Cfg.SMTP.Username,
+		Password: s.Cfg.SMTP.Password,
+		TLS:      s.Cfg.SMTP.TLS,
+	}
+	// Posts stored during the current second may still be coming in; leave
+	// them for the next digest.
+	until := time.Now().Add(-time.Second).Truncate(time.Second)
+
+	sent := 0
+	var failed []string
+	for _, sub := range subs {
+		log := s.Log.With("user", sub.Username, "email", sub.Email.String)
+		posts, err := database.GetDigestPosts(s.DB, sub.UserID, sub.LastDigestAt.Time, until)
+		if err != nil {
+			log.Error("could not collect digest posts", "err", err)
+			failed = append(failed, sub.Username)
+			continue
+		}
+		if len(posts) > 0 {
+			d := buildDigest(sub, posts)
+			text, html, err := digest.Render(d)
+			if err == nil && dryRun {
+				fmt.Printf("To: %s\nSubject: %s\n\n%s\n", sub.Email.String, d.Subject(), text)
+			} else if err == nil {
+				err = mail.Send(srv, mail.Message{
+					From:    s.Cfg.SMTP.From,
+					To:      sub.Email.String,
+					Subject: d.Subject(),
+					Date:    time.Now(),
+					Text:    text,
+					HTML:    html,
+				})
+			}
+			if err != nil {
+				log.Error("could not send digest", "err", err)
+				failed = append(failed, sub.Username)
+				continue
+			}
+			if !dryRun {
+				log.Info("sent digest", "posts", len(posts))
+				sent++
+			}
+		}
+		if dryRun {
+			continue
+		}
+		if err := database.MarkDigestSent(s.DB, sub.UserID, until); err != nil {
+			log.Error("could not record digest", "err", err)
+		}
+	}
+	return sent, failed, nil
+}
+
+// buildDigest groups posts, already ordered by feed, into a digest.
+func buildDigest(sub database.DigestSubscriber, posts []database.DigestPost) digest.Digest {
+	d := digest.Digest{Username: sub.Username, Since: sub.LastDigestAt.Time}
+	var lastFeed int64
+	for _, p := range posts {
+		if len(d.Feeds) == 0 || p.FeedID != lastFeed {
+			d.Feeds = append(d.Feeds, digest.Feed{Name: p.FeedName})
+			lastFeed = p.FeedID
+		}
+		f := &d.Feeds[len(d.Feeds)-1]
+		f.Posts = append(f.Posts, digest.Post{
+			Title:       p.Title,
+			URL:         p.URL,
+			Description: p.Description.String,
+			PublishedAt: p.PublishedAt.Time,
+		})
+	}
+	return d
+}
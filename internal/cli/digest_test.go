@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"blogo/internal/config"
+	"blogo/internal/database"
+	"database/sql"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is an SMTP server that keeps the messages it accepts.
+type fakeSMTP struct {
+	port int
+
+	mu       sync.Mutex
+	reject   bool // refuse every recipient
+	messages []string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeSMTP{port: ln.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(c net.Conn) {
+	defer c.Close()
+	tp := textproto.NewConn(c)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			f.mu.Lock()
+			reject := f.reject
+			f.mu.Unlock()
+			if reject {
+				tp.PrintfLine("550 mailbox unavailable")
+			} else {
+				tp.PrintfLine("250 OK")
+			}
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			body, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, string(body))
+			f.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// sent returns the messages accepted so far.
+func (f *fakeSMTP) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...)
+}
+
+func (f *fakeSMTP) setReject(reject bool) {
+	f.mu.Lock()
+	f.reject = reject
+	f.mu.Unlock()
+}
+
+// digestState returns a State sending mail through f, with a user "ann"
+// whose daily digest is due, following the feeds Beta and Alpha, each with
+// two posts.
+func digestState(t *testing.T, f *fakeSMTP) (s *State, userID int64) {
+	t.Helper()
+	s = newTestState(t)
+	s.Cfg = &config.Config{SMTP: config.SMTP{
+		Host: "127.0.0.1",
+		Port: f.port,
+		From: "blogo <blogo@example.com>",
+		TLS:  "none",
+	}}
+	if err := database.RegisterUser(s.DB, "ann"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := database.GetUserID(s.DB, "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Beta", "Alpha"} {
+		host := "https://" + strings.ToLower(name) + ".example.com"
+		feedID, err := database.CreateFeed(s.DB, name, host+"/feed", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.CreateFeedFollow(s.DB, userID, feedID); err != nil {
+			t.Fatal(err)
+		}
+		ingest(t, s, feedID, host+"/1", host+"/2")
+	}
+	if err := database.SetUserEmail(s.DB, userID, sql.NullString{String: "ann@example.com", Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetDigestFrequency(s.DB, userID, sql.NullString{String: database.DigestDaily, Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	// Posts stored in the current second wait for the next digest.
+	database.MustExec(t, s.DB, `UPDATE posts SET created_at = datetime('now', '-1 hour');`)
+	database.MustExec(t, s.DB, `UPDATE users SET last_digest_at = datetime('now', '-2 days');`)
+	return s, userID
+}
+
+// lastDigestAt returns when the user's last digest was sent.
+func lastDigestAt(t *testing.T, s *State, userID int64) time.Time {
+	t.Helper()
+	d, err := database.GetDigestSubscriber(s.DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.LastDigestAt.Time
+}
+
+// readDigest parses a digest email into its subject and its plain-text and
+// HTML parts.
+func readDigest(t *testing.T, raw string) (subject, text, html string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	var types []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart() // decodes quoted-printable
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ := p.Header.Get("Content-Type")
+		types = append(types, typ)
+		switch typ {
+		case "text/plain; charset=utf-8":
+			text = string(body)
+		case "text/html; charset=utf-8":
+			html = string(body)
+		}
+	}
+	if len(types) != 2 || text == "" || html == "" {
+		t.Fatalf("parts %q, want text/plain then text/html", types)
+	}
+	return subject, text, html
+}
+
+// assertInOrder checks that each of want appears in s after the previous one.
+func assertInOrder(t *testing.T, what, s string, want ...string) {
+	t.Helper()
+	rest := s
+	for _, w := range want {
+		i := strings.Index(rest, w)
+		if i < 0 {
+			t.Errorf("%s: %q missing or out of order in:\n%s", what, w, s)
+			return
+		}
+		rest = rest[i+len(w):]
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	f := newFakeSMTP(t)
+	s, userID := digestState(t, f)
+	before := lastDigestAt(t, s, userID)
+
+	sent, failed, err := sendDigests(s, false, false)
+	if err != nil || sent != 1 || len(failed) != 0 {
+		t.Fatalf("sendDigests = %d, %q, %v; want 1 sent", sent, failed, err)
+	}
+	msgs := f.sent()
+	if len(msgs) != 1 {
+		t.Fatalf("server got %d messages, want 1", len(msgs))
+	}
+	subject, text, html := readDigest(t, msgs[0])
+	if want := "blogo: 4 new post(s) from 2 feed(s)"; subject != want {
+		t.Errorf("subject %q, want %q", subject, want)
+	}
+	// Grouped by feed, in order of name.
+	assertInOrder(t, "text", text,
+		"== Alpha ==", "https://alpha.example.com/", "https://alpha.example.com/",
+		"== Beta ==", "https://beta.example.com/", "https://beta.example.com/")
+	assertInOrder(t, "html", html,
+		">Alpha</h2>", `href="https://alpha.example.com/`, `href="https://alpha.example.com/`,
+		">Beta</h2>", `href="https://beta.example.com/`, `href="https://beta.example.com/`)
+
+	if after := lastDigestAt(t, s, userID); !after.After(before) {
+		t.Errorf("last_digest_at %v, want it advanced from %v", after, before)
+	}
+
+	// Nothing is due until tomorrow.
+	if sent, _, err := sendDigests(s, false, false); err != nil || sent != 0 || len(f.sent()) != 1 {
+		t.Errorf("second run: %d sent, %v; want nothing sent", sent, err)
+	}
+}
+
+// TestSendDigestsFailure checks that a digest the server refuses is sent in
+// full on the next run.
+func TestSendDigestsFailure(t *testing.T) {
+	f := newFakeSMTP(t)
+	s, userID := digestState(t, f)
+	before := lastDigestAt(t, s, userID)
+
+	f.setReject(true)
+	sent, failed, err := sendDigests(s, false, false)
+	if err != nil || sent != 0 || len(failed) != 1 || failed[0] != "ann" {
+		t.Fatalf("sendDigests = %d, %q, %v; want ann failed", sent, failed, err)
+	}
+	if after := lastDigestAt(t, s, userID); !after.Equal(before) {
+		t.Errorf("last_digest_at moved from %v to %v after a failed send", before, after)
+	}
+
+	f.setReject(false)
+	sent, failed, err = sendDigests(s, false, false)
+	if err != nil || sent != 1 || len(failed) != 0 {
+		t.Fatalf("retry: sendDigests = %d, %q, %v; want 1 sent", sent, failed, err)
+	}
+	msgs := f.sent()
+	if len(msgs) != 1 {
+		t.Fatalf("server got %d messages, want 1", len(msgs))
+	}
+	if subject, _, _ := readDigest(t, msgs[0]); subject != "blogo: 4 new post(s) from 2 feed(s)" {
+		t.Errorf("retry: subject %q, want all 4 posts", subject)
+	}
+	if after := lastDigestAt(t, s, userID); !after.After(before) {
+		t.Errorf("last_digest_at %v, want it advanced from %v", after, before)
+	}
+}
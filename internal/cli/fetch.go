@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HandlerFetch fetches every due feed once and exits, for running blogo
+// from cron instead of as a long-lived aggregator.
+func HandlerFetch(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"workers": true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("%s: usage: fetch [--workers N]", cmd.Name)
+	}
+	a, err := newOneShotAggregator(s, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+
+	var sum runSummary
+	err = runLocked(s, func(ctx context.Context) error {
+		due, err := database.GetDueFeeds(s.DB, 0)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			a.deliverWebhooks(ctx)
+			fmt.Println("No feeds are due.")
+			return nil
+		}
+		sum = a.fetchAll(ctx, due)
+		a.pruneHistory()
+		a.prunePosts()
+		fmt.Println("Fetched due feeds:", sum)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	return failedFeedsError(cmd, sum)
+}
+
+// HandlerRefresh fetches the given feeds, or all feeds the current user
+// follows, immediately regardless of their schedule. Like fetch, it holds
+// the aggregator lock while fetching.
+func HandlerRefresh(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"workers": true, "mine": false})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	mine := opts["mine"] != ""
+	if mine == (len(args) > 0) {
+		return fmt.Errorf("%s: usage: refresh <feed-url...> | refresh --mine [--workers N]", cmd.Name)
+	}
+	a, err := newOneShotAggregator(s, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+
+	var feeds []database.FeedToFetch
+	if mine {
+		if s.Cfg.CurrentUser == "" {
+			return fmt.Errorf("%s: you must login first (try `login <username>`)", cmd.Name)
+		}
+		uid, err := database.GetUserID(s.DB, s.Cfg.CurrentUser)
+		if err != nil {
+			return fmt.Errorf("%s: could not fetch user record: %w", cmd.Name, err)
+		}
+		if feeds, err = database.GetFeedsToFetchForUser(s.DB, uid); err != nil {
+			return err
+		}
+		if len(feeds) == 0 {
+			fmt.Println("You are not following any enabled feeds.")
+			return nil
+		}
+	} else {
+		for _, u := range args {
+			ff, err := database.GetFeedToFetch(s.DB, u)
+			if err != nil {
+				return fmt.Errorf("%s: %w", cmd.Name, err)
+			}
+			feeds = append(feeds, ff)
+		}
+	}
+
+	var sum runSummary
+	err = runLocked(s, func(ctx context.Context) error {
+		sum = a.fetchAll(ctx, feeds)
+		fmt.Printf("Refreshed %d feed(s): %s\n", len(feeds), sum)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	return failedFeedsError(cmd, sum)
+}
+
+// newOneShotAggregator returns an aggregator configured from the config and
+// the --workers option.
+func newOneShotAggregator(s *State, opts map[string]string) (*aggregator, error) {
+	a := newAggregator(s)
+	if v, ok := opts["workers"]; ok {
+		var err error
+		if a.workers, err = strconv.Atoi(v); err != nil || a.workers < 1 {
+			return nil, fmt.Errorf("invalid worker count %q", v)
+		}
+	}
+	return a, nil
+}
+
+// failedFeedsError returns an error naming the feeds that failed in sum, so
+// the command exits with a non-zero status.
+func failedFeedsError(cmd Command, sum runSummary) error {
+	if sum.Failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %d feed(s) failed: %s", cmd.Name, sum.Failed, strings.Join(sum.FailedURLs, ", "))
+}
@@ -1,54 +1,20 @@
 package cli
 
 import (
+	"blogo/internal/cron"
 	"blogo/internal/database"
 	"blogo/internal/rss"
 	"blogo/internal/utils"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
 	"time"
 )
 
-func scrapeFeeds(s *State) {
-	feeds, err := database.GetAllFeeds(s.DB)
-	if err != nil {
-		fmt.Println("scrapeFeeds: could not list feeds:", err)
-		return
-	}
-
-	for _, ff := range feeds {
-		if err := database.MarkFeedFetched(s.DB, ff.ID); err != nil {
-			fmt.Println("scrapeFeeds: mark fetched:", err)
-			// continue on to the fetch even if marking fails
-		}
-
-		feed, err := rss.FetchFeed(ff.URL)
-		if err != nil {
-			fmt.Printf("failed to fetch %q: %v\n", ff.URL, err)
-			continue
-		}
-		fmt.Printf("=== Feed: %s (%s) ===\n", feed.Channel.Title, ff.URL)
-		for _, item := range feed.Channel.Items {
-			pub, err := utils.ParsePubDate(item.PubDate)
-			if err != nil {
-				fmt.Printf("warning: could not parse date %q: %v\n", item.PubDate, err)
-			}
-			post := &database.Post{
-				Title:       item.Title,
-				URL:         item.Link,
-				Description: sql.NullString{String: item.Description, Valid: item.Description != ""},
-				PublishedAt: sql.NullTime{Time: pub, Valid: !pub.IsZero()},
-				FeedID:      ff.ID,
-			}
-			if err := database.CreatePost(s.DB, post); err != nil {
-				fmt.Printf("error saving post %q: %v\n", post.URL, err)
-			}
-		}
-		fmt.Println()
-	}
-}
-
 func HandlerLogin(s *State, cmd Command) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("%s: usage: login <userName>", cmd.Name)
@@ -85,16 +51,7 @@ func HandlerReset(s *State, _ Command) error {
 	if err := database.DropAllTables(s.DB); err != nil {
 		return err
 	}
-	if err := database.CreateUserTable(s.DB); err != nil {
-		return err
-	}
-	if err := database.CreateFeedsTable(s.DB); err != nil {
-		return err
-	}
-	if err := database.CreateFeedFollowsTable(s.DB); err != nil {
-		return err
-	}
-	if err := database.CreatePostsTable(s.DB); err != nil {
+	if _, err := database.Migrate(s.DB); err != nil {
 		return err
 	}
 
@@ -118,23 +75,165 @@ func HandlerUsers(s *State, _ Command) error {
 }
 
 func HandlerAgg(s *State, cmd Command) error {
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("%s: usage: agg <interval>", cmd.Name)
+	if len(cmd.Args) == 1 {
+		switch cmd.Args[0] {
+		case "status":
+			return aggStatus(s)
+		case "stop":
+			if err := aggStop(s); err != nil {
+				return fmt.Errorf("%s: %w", cmd.Name, err)
+			}
+			return nil
+		}
+	}
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{
+		"workers": true, "daemon": false, "metrics-addr": true, "jitter": true,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%s: usage: agg [min-interval|cron-expr] [--jitter D] [--workers N] [--metrics-addr host:port] [--daemon] | agg status | agg stop", cmd.Name)
+	}
+	a := newAggregator(s)
+	when := s.Cfg.Schedule
+	if len(args) == 1 {
+		when = args[0]
+	}
+	if when != "" {
+		// A duration (“1s”, “1m”, “1h”) sets the minimum interval; anything
+		// else must be a cron schedule.
+		if d, err := time.ParseDuration(when); err == nil {
+			if d <= 0 {
+				return fmt.Errorf("%s: invalid duration %q", cmd.Name, when)
+			}
+			a.minInterval = d
+		} else if a.schedule, err = cron.Parse(when); err != nil {
+			return fmt.Errorf("%s: %q is neither a duration nor a cron schedule: %w", cmd.Name, when, err)
+		}
+	}
+	if v, ok := opts["jitter"]; ok {
+		if a.jitter, err = time.ParseDuration(v); err != nil || a.jitter < 0 {
+			return fmt.Errorf("%s: invalid jitter %q", cmd.Name, v)
+		}
+	}
+	if v, ok := opts["workers"]; ok {
+		if a.workers, err = strconv.Atoi(v); err != nil || a.workers < 1 {
+			return fmt.Errorf("%s: invalid worker count %q", cmd.Name, v)
+		}
 	}
-	// parse “1s”, “1m”, “1h”
-	d, err := time.ParseDuration(cmd.Args[0])
+	if a.minInterval > a.maxInterval {
+		return fmt.Errorf("%s: minimum interval %s exceeds maximum %s", cmd.Name, a.minInterval, a.maxInterval)
+	}
+	if opts["daemon"] != "" {
+		// Re-run the whole command line, global options included.
+		if err := startDaemon(s, withoutFlag(os.Args[1:], "daemon")); err != nil {
+			return fmt.Errorf("%s: %w", cmd.Name, err)
+		}
+		return nil
+	}
+
+	metricsAddr := s.Cfg.MetricsAddr
+	if v, ok := opts["metrics-addr"]; ok {
+		metricsAddr = v
+	}
+	err = runLocked(s, func(ctx context.Context) error {
+		if metricsAddr != "" {
+			if err := serveMetrics(ctx, s.Log, metricsAddr, a.metrics.registry); err != nil {
+				return err
+			}
+		}
+		if a.schedule != nil {
+			fmt.Printf("Collecting due feeds on schedule %q (up to %s late), with %d workers\n",
+				a.schedule, a.jitter, a.workers)
+		} else {
+			fmt.Printf("Collecting each feed every %s to %s, adapting to how often it posts, with %d workers\n",
+				a.minInterval, a.maxInterval, a.workers)
+		}
+		a.run(ctx)
+		fmt.Println("Aggregator stopped.")
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("%s: invalid duration %q: %w", cmd.Name, cmd.Args[0], err)
+		return fmt.Errorf("%s: %w", cmd.Name, err)
 	}
+	return nil
+}
 
-	fmt.Printf("Collecting feeds every %s\n", d)
-	scrapeFeeds(s)
+func HandlerBackfill(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"max-pages": true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%s: usage: backfill <feed-url> [--max-pages N]", cmd.Name)
+	}
+	maxPages := 10
+	if v, ok := opts["max-pages"]; ok {
+		if maxPages, err = strconv.Atoi(v); err != nil || maxPages < 1 {
+			return fmt.Errorf("%s: invalid page count %q", cmd.Name, v)
+		}
+	}
+	feedURL := args[0]
+	feedID, name, err := database.GetFeedByURL(s.DB, feedURL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+
+	// Pages are fetched like any feed: within the host's limits and
+	// robots.txt, and not while an aggregator is fetching too.
+	a := newAggregator(s)
+	total := 0
+	err = runLocked(s, func(ctx context.Context) error {
+		seen := make(map[string]bool)
+		pageURL := feedURL
+		for page := 1; page <= maxPages && pageURL != "" && !seen[pageURL]; page++ {
+			seen[pageURL] = true
+			res := a.fetchPolitely(ctx, database.FeedToFetch{ID: feedID, URL: pageURL})
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			err := res.err
+			switch {
+			case res.skipReason != "":
+				err = errors.New(res.skipReason)
+			case err == nil && !res.deferredUntil.IsZero():
+				err = fmt.Errorf("host asked us to back off until %s", res.deferredUntil.Local().Format(time.DateTime))
+			}
+			if err != nil {
+				if page == 1 {
+					return fmt.Errorf("fetch %q: %w", pageURL, err)
+				}
+				// Running off the end of ?paged=N archives usually looks like this.
+				fmt.Printf("page %d (%s): %v, stopping\n", page, pageURL, err)
+				break
+			}
+			feed := res.rss
+			saved, err := ingestItems(s, a.feedLog(database.FeedToFetch{ID: feedID, URL: pageURL}), feedID, feed.Channel.Items)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", page, err)
+			}
+			inserted := len(saved.Inserted)
+			total += inserted
+			fmt.Printf("page %d (%s): %d items, %d new\n", page, pageURL, len(feed.Channel.Items), inserted)
 
-	ticker := time.NewTicker(d)
-	for {
-		<-ticker.C
-		scrapeFeeds(s)
+			// The first page is the live feed and usually already known; past
+			// it, a page without anything new means the rest of history is
+			// stored.
+			if page > 1 && inserted == 0 {
+				fmt.Println("reached already-known posts, stopping")
+				break
+			}
+			pageURL = feed.NextPage(pageURL, page)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
 	}
+
+	fmt.Printf("Backfilled %d posts into %s\n", total, name)
+	return nil
 }
 
 func HandlerBrowse(s *State, cmd Command, user database.User) error {
@@ -158,17 +257,138 @@ func HandlerBrowse(s *State, cmd Command, user database.User) error {
 		fmt.Println("No posts available.")
 		return nil
 	}
+	printPosts(posts)
+	return nil
+}
+
+// printPosts renders posts in the browse listing format.
+func printPosts(posts []database.Post) {
 	for i, p := range posts {
 		fmt.Printf("===========================Post %d============================\n", i+1)
-		fmt.Printf("• %s (%s)\n  published: %s\n  %s\n\n",
+		fmt.Printf("• %s (%s)\n  published: %s\n  %s\n",
 			p.Title, p.URL,
 			p.PublishedAt.Time.Format(time.RFC1123),
 			utils.Truncate(p.Description.String, 100),
 		)
+		if p.Revisions > 0 {
+			fmt.Printf("  edited %d time(s), see `revisions %s`\n", p.Revisions, p.URL)
+		}
+		if p.Starred {
+			fmt.Println("  ★ starred")
+		}
+		if p.Note.Valid {
+			fmt.Printf("  note: %s\n", p.Note.String)
+		}
+		fmt.Println()
+	}
+}
+
+func HandlerPreview(s *State, cmd Command) error {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("%s: usage: preview <feed-url> [limit]", cmd.Name)
+	}
+	limit := 5
+	if len(cmd.Args) == 2 {
+		l, err := strconv.Atoi(cmd.Args[1])
+		if err != nil || l < 1 {
+			return fmt.Errorf("%s: invalid limit %q", cmd.Name, cmd.Args[1])
+		}
+		limit = l
+	}
+
+	feed, err := rss.FetchFeed(s.Ctx, cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("%s: fetch %q: %w", cmd.Name, cmd.Args[0], err)
+	}
+
+	posts := make([]database.Post, 0, len(feed.Channel.Items))
+	for _, item := range feed.Channel.Items {
+		posts = append(posts, *itemToPost(s.Log.With("url", cmd.Args[0]), item, 0))
+	}
+	// Same order as browse: newest first, undated posts last.
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].PublishedAt.Valid != posts[j].PublishedAt.Valid {
+			return posts[i].PublishedAt.Valid
+		}
+		return posts[i].PublishedAt.Time.After(posts[j].PublishedAt.Time)
+	})
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	fmt.Printf("=== Feed: %s (%s) ===\n", feed.Channel.Title, cmd.Args[0])
+	if feed.Channel.Description != "" {
+		fmt.Printf("%s\n", utils.Truncate(feed.Channel.Description, 100))
+	}
+	fmt.Printf("%d items, showing the latest %d\n\n", len(feed.Channel.Items), len(posts))
+	if len(posts) == 0 {
+		fmt.Println("No posts available.")
+		return nil
+	}
+	printPosts(posts)
+	return nil
+}
+
+func HandlerRevisions(s *State, cmd Command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: revisions <post-url>", cmd.Name)
+	}
+	post, err := database.GetPostByURL(s.DB, cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	revs, err := database.GetPostRevisions(s.DB, post.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("• %s (%s)\n", post.Title, post.URL)
+	if len(revs) == 0 {
+		fmt.Println("  No edits recorded.")
+		return nil
+	}
+	for i, r := range revs {
+		// Each revision holds the content before an edit; the content after
+		// it is the next revision, or the post itself for the latest edit.
+		after := database.PostRevision{
+			Title:           post.Title,
+			Description:     post.Description,
+			PublishedAt:     post.PublishedAt,
+			SourceUpdatedAt: post.SourceUpdatedAt,
+		}
+		if i+1 < len(revs) {
+			after = revs[i+1]
+		}
+		fmt.Printf("--- Edit %d, detected %s ---\n", i+1, r.CreatedAt.Format(time.RFC1123))
+		if r.Title != after.Title {
+			fmt.Printf("  title:\n  - %s\n  + %s\n", r.Title, after.Title)
+		}
+		if r.Description.String != after.Description.String {
+			fmt.Printf("  description:\n  - %s\n  + %s\n",
+				utils.Truncate(r.Description.String, 100),
+				utils.Truncate(after.Description.String, 100),
+			)
+		}
+		if !r.PublishedAt.Time.Equal(after.PublishedAt.Time) {
+			fmt.Printf("  published:\n  - %s\n  + %s\n",
+				formatOptionalTime(r.PublishedAt), formatOptionalTime(after.PublishedAt))
+		}
+		if !r.SourceUpdatedAt.Time.Equal(after.SourceUpdatedAt.Time) {
+			fmt.Printf("  updated:\n  - %s\n  + %s\n",
+				formatOptionalTime(r.SourceUpdatedAt), formatOptionalTime(after.SourceUpdatedAt))
+		}
 	}
 	return nil
 }
 
+// formatOptionalTime formats a nullable time for display.
+func formatOptionalTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "(none)"
+	}
+	return t.Time.Format(time.RFC1123)
+}
+
 func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 	if len(cmd.Args) != 2 {
 		return fmt.Errorf("%s: usage: addfeed <Name> <url>", cmd.Name)
@@ -202,6 +422,13 @@ func HandlerFeeds(s *State, _ Command) error {
 
 	for _, f := range feeds {
 		fmt.Printf("%s → %s (added by %s)\n", f.Name, f.URL, f.Username)
+		if f.NextFetchAt.Valid {
+			fmt.Printf("  polled every %s, next fetch %s\n",
+				f.FetchInterval, f.NextFetchAt.Time.Local().Format(time.RFC1123))
+		}
+		if f.SkipReason != "" {
+			fmt.Printf("  skipped: %s\n", f.SkipReason)
+		}
 	}
 	return nil
 }
@@ -237,7 +464,14 @@ func HandlerFollowing(s *State, _ Command, user database.User) error {
 		return nil
 	}
 	for _, ff := range follows {
-		fmt.Printf("- %s (%s)\n", ff.FeedName, ff.FeedURL)
+		if ff.GroupName != "" {
+			fmt.Printf("- %s (%s) [%s]\n", ff.FeedName, ff.FeedURL, ff.GroupName)
+		} else {
+			fmt.Printf("- %s (%s)\n", ff.FeedName, ff.FeedURL)
+		}
+		if ff.FeedOwner == user.ID && ff.Broken() {
+			fmt.Printf("  ! %s\n", describeHealth(ff.FeedHealth))
+		}
 	}
 	return nil
 }
@@ -255,3 +489,53 @@ func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 	fmt.Printf("Unfollowed feed %q for user %s\n", feedURL, user.Username)
 	return nil
 }
+
+func HandlerBroken(s *State, _ Command) error {
+	feeds, err := database.GetBrokenFeeds(s.DB)
+	if err != nil {
+		return err
+	}
+	if len(feeds) == 0 {
+		fmt.Println("All feeds are healthy.")
+		return nil
+	}
+	for _, f := range feeds {
+		fmt.Printf("%s → %s (added by %s)\n", f.Name, f.URL, f.Username)
+		fmt.Printf("  %s\n", describeHealth(f.FeedHealth))
+		if f.LastSuccessAt.Valid {
+			fmt.Printf("  last success: %s\n", f.LastSuccessAt.Time.Local().Format(time.RFC1123))
+		} else {
+			fmt.Println("  last success: never")
+		}
+	}
+	return nil
+}
+
+func HandlerEnable(s *State, cmd Command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: enable <feed-url>", cmd.Name)
+	}
+	if err := database.EnableFeed(s.DB, cmd.Args[0]); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	fmt.Printf("Re-enabled %s; it will be fetched on the next aggregator pass.\n", cmd.Args[0])
+	return nil
+}
+
+// describeHealth summarizes why a feed is broken.
+func describeHealth(h database.FeedHealth) string {
+	var msg string
+	if h.DisabledAt.Valid {
+		msg = fmt.Sprintf("disabled since %s after %d failed fetches (re-enable with `enable <url>`)",
+			h.DisabledAt.Time.Local().Format(time.RFC1123), h.ConsecutiveFailures)
+	} else {
+		msg = fmt.Sprintf("failing: %d fetches in a row", h.ConsecutiveFailures)
+	}
+	if h.LastStatus.Valid {
+		msg += fmt.Sprintf("; last status %d", h.LastStatus.Int64)
+	}
+	if h.LastError.Valid {
+		msg += "; last error: " + h.LastError.String
+	}
+	return msg
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// defaultHistoryLimit is how many runs or fetches `history` shows.
+const defaultHistoryLimit = 20
+
+func HandlerHistory(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"limit": true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%s: usage: history [feed-url] [--limit N]", cmd.Name)
+	}
+	limit := defaultHistoryLimit
+	if v, ok := opts["limit"]; ok {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return fmt.Errorf("%s: invalid limit %q", cmd.Name, v)
+		}
+	}
+	if len(args) == 0 {
+		return printRuns(s, limit)
+	}
+
+	feedID, name, err := database.GetFeedByURL(s.DB, args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	entries, err := database.GetFetchLog(s.DB, feedID, limit)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("• %s (%s)\n", name, args[0])
+	if len(entries) == 0 {
+		fmt.Printf("  No fetches recorded in the last %d days.\n", s.Cfg.HistoryDays)
+		return nil
+	}
+	for _, e := range entries {
+		status := "---"
+		if e.Status.Valid {
+			status = strconv.FormatInt(e.Status.Int64, 10)
+		}
+		fmt.Printf("  %s  %-8s %s  %8s  %6s",
+			e.FetchedAt.Local().Format(time.DateTime), e.Outcome, status,
+			formatBytes(e.Bytes), e.Duration.Round(time.Millisecond))
+		if e.Outcome == database.FetchOK {
+			fmt.Printf("  %d items, %d new", e.ItemsSeen, e.ItemsInserted)
+		}
+		if e.Error.Valid {
+			fmt.Printf("  %s", e.Error.String)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// printRuns lists the most recent aggregator runs.
+func printRuns(s *State, limit int) error {
+	runs, err := database.GetFetchRuns(s.DB, limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No aggregator runs recorded in the last %d days.\n", s.Cfg.HistoryDays)
+		return nil
+	}
+	for _, r := range runs {
+		took := "unfinished"
+		if r.FinishedAt.Valid {
+			took = r.FinishedAt.Time.Sub(r.StartedAt).Round(time.Second).String()
+		}
+		fmt.Printf("#%d  %s  %-10s  %d feeds: %d succeeded, %d failed, %d skipped, %d new posts\n",
+			r.ID, r.StartedAt.Local().Format(time.DateTime), took,
+			r.FeedsAttempted, r.Succeeded, r.Failed, r.Skipped, r.NewPosts)
+	}
+	return nil
+}
+
+// formatBytes renders a size in B, KiB or MiB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
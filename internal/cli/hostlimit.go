@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostLimiter keeps the aggregator polite towards each host: at most
+// concurrency requests in flight, request starts at least spacing apart, and
+// no requests at all while the host has asked us to back off.
+type hostLimiter struct {
+	mu          sync.Mutex
+	concurrency int
+	spacing     time.Duration
+	hosts       map[string]*hostState
+}
+
+type hostState struct {
+	active   int       // requests in flight
+	next     time.Time // earliest start of the next request
+	deferred time.Time // no requests before this time (Retry-After)
+}
+
+func newHostLimiter(concurrency int, spacing time.Duration) *hostLimiter {
+	return &hostLimiter{
+		concurrency: max(concurrency, 1),
+		spacing:     spacing,
+		hosts:       make(map[string]*hostState),
+	}
+}
+
+// acquire waits until a request to host may start and reserves a slot for
+// it, to be returned with release. If the host is deferred it returns false
+// and the time the deferral ends, without reserving anything. It also
+// returns false, with a zero time, if ctx ends while waiting.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (bool, time.Time) {
+	for {
+		l.mu.Lock()
+		h := l.state(host)
+		now := time.Now()
+		if now.Before(h.deferred) {
+			l.mu.Unlock()
+			return false, h.deferred
+		}
+		if h.active < l.concurrency && !now.Before(h.next) {
+			h.active++
+			h.next = now.Add(l.spacing)
+			l.mu.Unlock()
+			return true, time.Time{}
+		}
+		wait := 50 * time.Millisecond
+		if h.active < l.concurrency {
+			wait = h.next.Sub(now)
+		}
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return false, time.Time{}
+		case <-time.After(wait):
+		}
+	}
+}
+
+// respace waits, keeping the slot taken with acquire, until another request
+// to host may start, and counts that request against the spacing. It lets a
+// slot make more than one request. It returns false if ctx ends while
+// waiting.
+func (l *hostLimiter) respace(ctx context.Context, host string) bool {
+	for {
+		l.mu.Lock()
+		h := l.state(host)
+		now := time.Now()
+		if !now.Before(h.next) {
+			h.next = now.Add(l.spacing)
+			l.mu.Unlock()
+			return true
+		}
+		wait := h.next.Sub(now)
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (l *hostLimiter) release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state(host).active--
+}
+
+// deferHost stops requests to host until the given time.
+func (l *hostLimiter) deferHost(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h := l.state(host); until.After(h.deferred) {
+		h.deferred = until
+	}
+}
+
+// state must be called with l.mu held.
+func (l *hostLimiter) state(host string) *hostState {
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostState{}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// hostOf returns the lower-cased host of a feed URL, "" if it has none.
+func hostOf(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// interleaveHosts orders feeds round-robin by host, so that workers spread
+// over many hosts instead of queueing behind one host's limit.
+func interleaveHosts(feeds []database.FeedToFetch) []database.FeedToFetch {
+	var order []string
+	byHost := make(map[string][]database.FeedToFetch)
+	for _, ff := range feeds {
+		host := hostOf(ff.URL)
+		if _, ok := byHost[host]; !ok {
+			order = append(order, host)
+		}
+		byHost[host] = append(byHost[host], ff)
+	}
+
+	out := make([]database.FeedToFetch, 0, len(feeds))
+	for len(out) < len(feeds) {
+		for _, host := range order {
+			if q := byHost[host]; len(q) > 0 {
+				out = append(out, q[0])
+				byHost[host] = q[1:]
+			}
+		}
+	}
+	return out
+}
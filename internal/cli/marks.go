@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// HandlerStar stars a post for the current user. Starred posts are kept
+// when old posts are pruned, regardless of retention limits.
+func HandlerStar(s *State, cmd Command, user database.User) error {
+	return setStarred(s, cmd, user, true)
+}
+
+// HandlerUnstar removes the current user's star from a post, letting it be
+// pruned again unless someone else starred or annotated it.
+func HandlerUnstar(s *State, cmd Command, user database.User) error {
+	return setStarred(s, cmd, user, false)
+}
+
+func setStarred(s *State, cmd Command, user database.User, starred bool) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: %s <post-url>", cmd.Name, cmd.Name)
+	}
+	post, err := database.GetPostByURL(s.DB, cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if err := database.StarPost(s.DB, user.ID, post.ID, starred); err != nil {
+		return err
+	}
+	if starred {
+		fmt.Printf("Starred %q; it will be kept when old posts are pruned.\n", post.Title)
+	} else {
+		fmt.Printf("Unstarred %q.\n", post.Title)
+	}
+	return nil
+}
+
+// HandlerAnnotate sets the current user's note on a post, or removes it when
+// no note is given. Like starred posts, annotated ones are kept when old
+// posts are pruned.
+func HandlerAnnotate(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("%s: usage: annotate <post-url> [note...]", cmd.Name)
+	}
+	post, err := database.GetPostByURL(s.DB, cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	text := strings.TrimSpace(strings.Join(cmd.Args[1:], " "))
+	note := sql.NullString{String: text, Valid: text != ""}
+	if err := database.AnnotatePost(s.DB, user.ID, post.ID, note); err != nil {
+		return err
+	}
+	if note.Valid {
+		fmt.Printf("Annotated %q; it will be kept when old posts are pruned.\n", post.Title)
+	} else {
+		fmt.Printf("Removed your note from %q.\n", post.Title)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"blogo/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// aggMetrics are the Prometheus metrics the aggregator keeps.
+type aggMetrics struct {
+	registry      *metrics.Registry
+	fetches       *metrics.CounterVec
+	fetchDuration *metrics.Histogram
+	bytes         *metrics.Counter
+	postsInserted *metrics.Counter
+	parseErrors   *metrics.Counter
+	dbWrite       *metrics.Histogram
+}
+
+func newAggMetrics(a *aggregator) *aggMetrics {
+	r := metrics.NewRegistry()
+	m := &aggMetrics{
+		registry: r,
+		fetches: r.NewCounterVec("blogo_fetches_total",
+			`Feed requests by HTTP status, or "error" when no response was received.`, "status"),
+		fetchDuration: r.NewHistogram("blogo_fetch_duration_seconds",
+			"Time taken to download a feed.", metrics.ExponentialBuckets(0.05, 2, 10)),
+		bytes: r.NewCounter("blogo_fetch_bytes_total",
+			"Bytes of feed documents downloaded."),
+		postsInserted: r.NewCounter("blogo_posts_inserted_total",
+			"New posts stored."),
+		parseErrors: r.NewCounter("blogo_parse_errors_total",
+			"Feeds that were downloaded but could not be parsed."),
+		dbWrite: r.NewHistogram("blogo_db_write_duration_seconds",
+			"Time taken to store the outcome and posts of one fetch.", metrics.ExponentialBuckets(0.001, 2, 12)),
+	}
+	r.NewGaugeFunc("blogo_feeds_due", "Enabled feeds whose next fetch time has passed.",
+		func() (float64, error) {
+			n, err := database.CountDueFeeds(a.s.DB, 0)
+			return float64(n), err
+		})
+	r.NewGaugeFunc("blogo_feeds_overdue", "Enabled feeds that have been due for longer than the minimum poll interval.",
+		func() (float64, error) {
+			n, err := database.CountDueFeeds(a.s.DB, a.minInterval)
+			return float64(n), err
+		})
+	return m
+}
+
+// observeFetch counts a finished request. Feeds that were skipped or
+// deferred without a request are not counted.
+func (m *aggMetrics) observeFetch(res fetchResult) {
+	switch {
+	case res.status != 0:
+		m.fetches.Inc(strconv.Itoa(res.status))
+		if res.err != nil && res.status >= 200 && res.status <= 299 {
+			m.parseErrors.Inc()
+		}
+	case res.err != nil:
+		m.fetches.Inc("error")
+	default:
+		return
+	}
+	m.fetchDuration.Observe(res.duration.Seconds())
+	m.bytes.Add(float64(res.bytes))
+}
+
+// serveMetrics exposes the registry on addr at /metrics until ctx ends.
+// Returns an error if addr cannot be listened on.
+func serveMetrics(ctx context.Context, log *slog.Logger, addr string, r *metrics.Registry) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics listener: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("metrics listener failed", "err", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	log.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", ln.Addr()))
+	return nil
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = "migrate status | migrate up [version] | migrate down [version]"
+
+// HandlerMigrate shows the database's schema migrations, or applies or
+// reverts them. Other commands apply pending migrations on their own.
+func HandlerMigrate(s *State, cmd Command) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("%s: usage: %s", cmd.Name, migrateUsage)
+	}
+	sub := Command{Name: cmd.Name + " " + cmd.Args[0], Args: cmd.Args[1:]}
+	switch cmd.Args[0] {
+	case "status":
+		return migrateStatus(s, sub)
+	case "up":
+		return migrateUp(s, sub)
+	case "down":
+		return migrateDown(s, sub)
+	}
+	return fmt.Errorf("%s: usage: %s", cmd.Name, migrateUsage)
+}
+
+func migrateStatus(s *State, cmd Command) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("%s: usage: migrate status", cmd.Name)
+	}
+	states, err := database.GetMigrationStates(s.DB)
+	if err != nil {
+		return err
+	}
+	version, err := database.SchemaVersion(s.DB)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %d\n", version)
+	for _, st := range states {
+		applied := "pending"
+		switch {
+		case st.Unknown:
+			applied = "applied by a newer blogo"
+		case st.AppliedAt.Valid:
+			applied = "applied " + st.AppliedAt.Time.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%04d  %-22s %s\n", st.Version, st.Name, applied)
+	}
+	return nil
+}
+
+func migrateUp(s *State, cmd Command) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("%s: usage: migrate up [version]", cmd.Name)
+	}
+	migrations, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	target := len(migrations)
+	if len(cmd.Args) == 1 {
+		if target, err = parseVersion(cmd.Args[0]); err != nil {
+			return fmt.Errorf("%s: %w", cmd.Name, err)
+		}
+	}
+	version, err := database.SchemaVersion(s.DB)
+	if err != nil {
+		return err
+	}
+	if target < version && version <= len(migrations) {
+		return fmt.Errorf("%s: the database is already at version %d, use `migrate down %d` to go back", cmd.Name, version, target)
+	}
+	return migrateTo(s, target, "Applied")
+}
+
+func migrateDown(s *State, cmd Command) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("%s: usage: migrate down [version]", cmd.Name)
+	}
+	version, err := database.SchemaVersion(s.DB)
+	if err != nil {
+		return err
+	}
+	target := max(version-1, 0)
+	if len(cmd.Args) == 1 {
+		if target, err = parseVersion(cmd.Args[0]); err != nil {
+			return fmt.Errorf("%s: %w", cmd.Name, err)
+		}
+	}
+	if target > version {
+		return fmt.Errorf("%s: the database is at version %d, use `migrate up %d` to go forward", cmd.Name, version, target)
+	}
+	if err := migrateTo(s, target, "Reverted"); err != nil {
+		return err
+	}
+	fmt.Println("Note: any other command applies pending migrations again; run the older blogo next.")
+	return nil
+}
+
+// migrateTo moves the schema to target, printing each step as it is done.
+func migrateTo(s *State, target int, verb string) error {
+	done, err := database.MigrateTo(s.DB, target)
+	for _, m := range done {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(done) == 0 {
+		fmt.Printf("Already at version %d.\n", target)
+	}
+	return nil
+}
+
+func parseVersion(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"blogo/internal/opml"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+func HandlerImportOPML(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: import-opml <file>", cmd.Name)
+	}
+	f, err := os.Open(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	defer f.Close()
+
+	doc, err := opml.Parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+
+	var created, followed, failed int
+	for _, sub := range doc.Subscriptions() {
+		feedID, _, err := database.GetFeedByURL(s.DB, sub.URL)
+		if errors.Is(err, sql.ErrNoRows) {
+			if feedID, err = database.CreateFeed(s.DB, sub.Title, sub.URL, user.ID); err != nil {
+				s.Log.Warn("skipping subscription", "url", sub.URL, "err", err)
+				failed++
+				continue
+			}
+			created++
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", cmd.Name, err)
+		}
+
+		following, err := database.IsFollowing(s.DB, user.ID, feedID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd.Name, err)
+		}
+		if !following {
+			if _, err := database.CreateFeedFollow(s.DB, user.ID, feedID); err != nil {
+				s.Log.Warn("skipping subscription", "url", sub.URL, "err", err)
+				failed++
+				continue
+			}
+			followed++
+		}
+		if sub.Group != "" {
+			if err := database.SetFeedFollowGroup(s.DB, user.ID, feedID, sub.Group); err != nil {
+				return fmt.Errorf("%s: %w", cmd.Name, err)
+			}
+		}
+	}
+
+	fmt.Printf("Imported %s: %d feeds created, %d newly followed, %d failed\n",
+		cmd.Args[0], created, followed, failed)
+	return nil
+}
+
+func HandlerExportOPML(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("%s: usage: export-opml [file]", cmd.Name)
+	}
+	follows, err := database.GetFeedFollowsForUser(s.DB, user.ID)
+	if err != nil {
+		return err
+	}
+	subs := make([]opml.Subscription, 0, len(follows))
+	for _, ff := range follows {
+		subs = append(subs, opml.Subscription{Title: ff.FeedName, URL: ff.FeedURL, Group: ff.GroupName})
+	}
+
+	title := fmt.Sprintf("%s's blogo subscriptions", user.Username)
+	if len(cmd.Args) == 0 {
+		return opml.Write(os.Stdout, title, subs)
+	}
+
+	f, err := os.Create(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if err := opml.Write(f, title, subs); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	fmt.Printf("Exported %d feeds to %s\n", len(subs), cmd.Args[0])
+	return nil
+}
@@ -4,12 +4,31 @@ func RegisterAllCommands(c *Commands) {
 	c.Register("login", HandlerLogin)
 	c.Register("register", HandlerRegister)
 	c.Register("reset", HandlerReset)
+	c.Register("migrate", HandlerMigrate)
 	c.Register("users", HandlerUsers)
 	c.Register("agg", HandlerAgg)
+	c.Register("fetch", HandlerFetch)
+	c.Register("refresh", HandlerRefresh)
+	c.Register("backfill", HandlerBackfill)
 	c.Register("browse", MiddlewareLoggedIn(HandlerBrowse))
+	c.Register("revisions", HandlerRevisions)
+	c.Register("star", MiddlewareLoggedIn(HandlerStar))
+	c.Register("unstar", MiddlewareLoggedIn(HandlerUnstar))
+	c.Register("annotate", MiddlewareLoggedIn(HandlerAnnotate))
+	c.Register("prune", HandlerPrune)
+	c.Register("retention", HandlerRetention)
+	c.Register("history", HandlerHistory)
 	c.Register("addfeed", MiddlewareLoggedIn(HandlerAddFeed))
 	c.Register("feeds", HandlerFeeds)
+	c.Register("broken", HandlerBroken)
+	c.Register("enable", HandlerEnable)
+	c.Register("validate", HandlerValidate)
+	c.Register("preview", HandlerPreview)
 	c.Register("follow", MiddlewareLoggedIn(HandlerFollow))
 	c.Register("following", MiddlewareLoggedIn(HandlerFollowing))
 	c.Register("unfollow", MiddlewareLoggedIn(HandlerUnfollow))
+	c.Register("import-opml", MiddlewareLoggedIn(HandlerImportOPML))
+	c.Register("export-opml", MiddlewareLoggedIn(HandlerExportOPML))
+	c.Register("webhook", MiddlewareLoggedIn(HandlerWebhook))
+	c.Register("digest", HandlerDigest)
 }
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// HandlerPrune deletes posts past their feed's retention limits, except
+// starred and annotated ones.
+func HandlerPrune(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"dry-run": false, "vacuum": false})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("%s: usage: prune [--dry-run] [--vacuum]", cmd.Name)
+	}
+	dryRun := opts["dry-run"] != ""
+
+	global := database.Retention{Days: s.Cfg.RetainDays, Items: s.Cfg.RetainItems}
+	pruned, err := database.PrunePosts(s.DB, global, dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	var total int64
+	for _, pf := range pruned {
+		total += pf.Posts
+		fmt.Printf("• %s (%s): %d post(s)\n", pf.Name, pf.URL, pf.Posts)
+	}
+	fmt.Printf("%s %d post(s) from %d feed(s).\n", verb, total, len(pruned))
+
+	if opts["vacuum"] != "" && !dryRun {
+		if err := database.Vacuum(s.DB); err != nil {
+			return err
+		}
+		fmt.Println("Database vacuumed.")
+	}
+	return nil
+}
+
+// HandlerRetention shows or changes how long a feed's posts are kept.
+// Without a feed, it shows the global limits from the config.
+func HandlerRetention(s *State, cmd Command) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"days": true, "items": true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	global := database.Retention{Days: s.Cfg.RetainDays, Items: s.Cfg.RetainItems}
+	if len(args) == 0 && len(opts) == 0 {
+		fmt.Printf("Global retention: %s\n", describeRetention(global))
+		return nil
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%s: usage: retention [feed-url [--days N|default] [--items N|default]]", cmd.Name)
+	}
+	url := args[0]
+
+	r, err := database.GetFeedRetention(s.DB, url)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if v, ok := opts["days"]; ok {
+		if r.Days, err = parseRetentionLimit(v); err != nil {
+			return fmt.Errorf("%s: invalid days %q", cmd.Name, v)
+		}
+	}
+	if v, ok := opts["items"]; ok {
+		if r.Items, err = parseRetentionLimit(v); err != nil {
+			return fmt.Errorf("%s: invalid items %q", cmd.Name, v)
+		}
+	}
+	if len(opts) > 0 {
+		if err := database.SetFeedRetention(s.DB, url, r); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Retention of %s: %s", url, describeRetention(r.Effective(global)))
+	if !r.Days.Valid && !r.Items.Valid {
+		fmt.Print(" (global)")
+	}
+	fmt.Println()
+	return nil
+}
+
+// parseRetentionLimit parses a --days or --items value: a count, where 0
+// keeps everything, or "default" to use the global limit.
+func parseRetentionLimit(v string) (sql.NullInt64, error) {
+	if v == "default" {
+		return sql.NullInt64{}, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return sql.NullInt64{}, fmt.Errorf("invalid retention limit %q", v)
+	}
+	return sql.NullInt64{Int64: int64(n), Valid: true}, nil
+}
+
+func describeRetention(r database.Retention) string {
+	switch {
+	case r.Days > 0 && r.Items > 0:
+		return fmt.Sprintf("posts from the last %d days and the newest %d", r.Days, r.Items)
+	case r.Days > 0:
+		return fmt.Sprintf("posts from the last %d days", r.Days)
+	case r.Items > 0:
+		return fmt.Sprintf("the newest %d posts", r.Items)
+	}
+	return "all posts"
+}
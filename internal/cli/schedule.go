@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"time"
+)
+
+// recentPostsForSchedule is how many of a feed's latest posts are used to
+// estimate how often it publishes.
+const recentPostsForSchedule = 10
+
+// defaultPollInterval is used for feeds without enough dated posts to
+// estimate a posting frequency.
+const defaultPollInterval = time.Hour
+
+// pollInterval picks how long to wait before fetching a feed again, given the
+// publish times of its latest posts, newest first.
+//
+// A feed is polled at about twice its average posting rate, so busy feeds are
+// checked often. The longer a feed has been quiet the slower it is polled, so
+// dormant feeds drift towards max. The result is clamped to [min, max].
+func pollInterval(published []time.Time, now time.Time, min, max time.Duration) time.Duration {
+	interval := defaultPollInterval
+	switch {
+	case len(published) >= 2:
+		span := published[0].Sub(published[len(published)-1])
+		avgGap := span / time.Duration(len(published)-1)
+		interval = avgGap / 2
+		if quiet := now.Sub(published[0]); quiet > avgGap {
+			interval = quiet / 2
+		}
+	case len(published) == 1:
+		interval = now.Sub(published[0]) / 2
+	}
+
+	if interval < min {
+		return min
+	}
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+// backoffInterval is the wait before retrying a feed that failed the given
+// number of times in a row: min, doubling with each failure, capped at max.
+func backoffInterval(failures int, min, max time.Duration) time.Duration {
+	interval := min
+	for i := 1; i < failures && interval < max; i++ {
+		interval *= 2
+	}
+	if interval > max {
+		return max
+	}
+	return interval
+}
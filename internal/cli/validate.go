@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"blogo/internal/rss"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+func HandlerValidate(s *State, cmd Command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: validate <url|file>", cmd.Name)
+	}
+	src := cmd.Args[0]
+
+	var body []byte
+	var header http.Header
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := rss.Fetch(s.Ctx, src)
+		if err != nil {
+			return fmt.Errorf("%s: fetch %q: %w", cmd.Name, src, err)
+		}
+		fmt.Printf("HTTP status:  %d %s\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+		body, header = resp.Body, resp.Header
+	} else {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd.Name, err)
+		}
+		body = b
+	}
+
+	r := rss.Validate(body, header)
+	fmt.Printf("Format:       %s\n", r.Format)
+	if r.ParseError != nil {
+		fmt.Printf("Parse error:  %v\n", r.ParseError)
+	} else {
+		fmt.Printf("Title:        %s\n", r.Title)
+		fmt.Printf("Items:        %d\n", r.Items)
+	}
+
+	printItemList("Items missing a link", r.MissingLink)
+	printItemList("Items missing a GUID", r.MissingGUID)
+	printItemList("Items missing a date", r.MissingDate)
+	printItemValues("Unparseable dates", r.BadDates)
+	printItemValues("Relative URLs", r.RelativeURLs)
+	if len(r.Duplicates) > 0 {
+		links := make([]string, 0, len(r.Duplicates))
+		for link := range r.Duplicates {
+			links = append(links, link)
+		}
+		sort.Strings(links)
+		fmt.Printf("Duplicate links (%d):\n", len(links))
+		for _, link := range links {
+			fmt.Printf("  %s: items %s\n", link, joinInts(r.Duplicates[link]))
+		}
+	}
+	if len(r.Encoding) > 0 {
+		fmt.Printf("Encoding problems (%d):\n", len(r.Encoding))
+		for _, e := range r.Encoding {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+
+	if r.Caching != nil {
+		fmt.Println("Caching headers:")
+		for _, h := range rss.CachingHeaders {
+			v, ok := r.Caching[h]
+			if !ok {
+				v = "(missing)"
+			}
+			fmt.Printf("  %-14s %s\n", h+":", v)
+		}
+		if r.Caching["ETag"] == "" && r.Caching["Last-Modified"] == "" {
+			fmt.Println("  warning: without ETag or Last-Modified, readers must download the full feed every time")
+		}
+	}
+
+	if n := r.Problems(); n > 0 {
+		return fmt.Errorf("%s: %d problem(s) found in %s", cmd.Name, n, src)
+	}
+	fmt.Println("No problems found.")
+	return nil
+}
+
+func printItemList(label string, items []int) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d): items %s\n", label, len(items), joinInts(items))
+}
+
+func printItemValues(label string, vals []rss.ItemValue) {
+	if len(vals) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", label, len(vals))
+	for _, v := range vals {
+		if v.Item == 0 {
+			fmt.Printf("  channel: %q\n", v.Value)
+		} else {
+			fmt.Printf("  item %d: %q\n", v.Item, v.Value)
+		}
+	}
+}
+
+func joinInts(ns []int) string {
+	parts := make([]string, len(ns))
+	for i, n := range ns {
+		parts[i] = fmt.Sprint(n)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"blogo/internal/webhooks"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookBatch       = 100              // deliveries loaded from the queue at a time
+	webhookWorkers     = 4                // deliveries sent in parallel
+	webhookTimeout     = 10 * time.Second // per request
+	webhookMaxAttempts = 8                // attempts before a delivery is given up on
+	webhookRetryBase   = 30 * time.Second // wait after the first failure, doubling after each
+	webhookRetryMax    = 6 * time.Hour
+)
+
+const webhookUsage = "webhook add <url> [--format json|slack|discord|matrix] [--secret S] | webhook list | webhook remove <id> | webhook log [id] [--limit N]"
+
+// HandlerWebhook manages the current user's webhooks, which receive the new
+// posts of every feed the user follows.
+func HandlerWebhook(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("%s: usage: %s", cmd.Name, webhookUsage)
+	}
+	sub := Command{Name: cmd.Name + " " + cmd.Args[0], Args: cmd.Args[1:]}
+	switch cmd.Args[0] {
+	case "add":
+		return webhookAdd(s, sub, user)
+	case "list":
+		return webhookList(s, sub, user)
+	case "remove":
+		return webhookRemove(s, sub, user)
+	case "log":
+		return webhookLog(s, sub, user)
+	}
+	return fmt.Errorf("%s: usage: %s", cmd.Name, webhookUsage)
+}
+
+func webhookAdd(s *State, cmd Command, user database.User) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"format": true, "secret": true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%s: usage: webhook add <url> [--format json|slack|discord|matrix] [--secret S]", cmd.Name)
+	}
+	u, err := url.Parse(args[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: %q is not an http(s) URL", cmd.Name, args[0])
+	}
+	format := webhooks.FormatJSON
+	if v, ok := opts["format"]; ok {
+		if !slices.Contains(webhooks.Formats, v) {
+			return fmt.Errorf("%s: unknown format %q, want one of %s", cmd.Name, v, strings.Join(webhooks.Formats, ", "))
+		}
+		format = v
+	}
+	secret, ok := opts["secret"]
+	if !ok {
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return fmt.Errorf("%s: generate secret: %w", cmd.Name, err)
+		}
+		secret = hex.EncodeToString(token)
+	}
+
+	id, err := database.CreateWebhook(s.DB, user.ID, args[0], format, secret)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Webhook %d added: new posts in feeds you follow will be sent to %s as %s.\n", id, args[0], format)
+	if secret != "" {
+		fmt.Printf("Requests are signed with HMAC-SHA256 in the %s header, using the secret:\n  %s\n",
+			webhooks.SignatureHeader, secret)
+	}
+	return nil
+}
+
+func webhookList(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("%s: usage: webhook list", cmd.Name)
+	}
+	hooks, err := database.GetWebhooksForUser(s.DB, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		fmt.Println("You have no webhooks.")
+		return nil
+	}
+	for _, w := range hooks {
+		fmt.Printf("%d  %s (%s)", w.ID, w.URL, w.Format)
+		if w.Pending > 0 {
+			fmt.Printf("  %d pending", w.Pending)
+		}
+		if w.Failed > 0 {
+			fmt.Printf("  %d failed", w.Failed)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func webhookRemove(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("%s: usage: webhook remove <id>", cmd.Name)
+	}
+	id, err := strconv.ParseInt(cmd.Args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: invalid webhook id %q", cmd.Name, cmd.Args[0])
+	}
+	if err := database.DeleteWebhook(s.DB, user.ID, id); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	fmt.Printf("Webhook %d removed.\n", id)
+	return nil
+}
+
+func webhookLog(s *State, cmd Command, user database.User) error {
+	args, opts, err := parseFlags(cmd.Args, map[string]bool{"limit": true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%s: usage: webhook log [id] [--limit N]", cmd.Name)
+	}
+	limit := defaultHistoryLimit
+	if v, ok := opts["limit"]; ok {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return fmt.Errorf("%s: invalid limit %q", cmd.Name, v)
+		}
+	}
+	var webhookID sql.NullInt64
+	if len(args) == 1 {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid webhook id %q", cmd.Name, args[0])
+		}
+		webhookID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	deliveries, err := database.GetDeliveryLog(s.DB, user.ID, webhookID, limit)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		fmt.Println("No deliveries recorded.")
+		return nil
+	}
+	for _, d := range deliveries {
+		status := "---"
+		if d.LastStatus.Valid {
+			status = strconv.FormatInt(d.LastStatus.Int64, 10)
+		}
+		fmt.Printf("#%d  webhook %d  %s  %-9s %s  %d attempt(s)  %s\n",
+			d.ID, d.WebhookID, d.CreatedAt.Local().Format(time.DateTime), d.Status, status,
+			d.Attempts, d.Post.Title)
+		switch {
+		case d.Status == database.DeliveryPending && d.Attempts > 0:
+			fmt.Printf("  retrying at %s: %s\n", d.NextAttemptAt.Local().Format(time.DateTime), d.LastError.String)
+		case d.LastError.Valid:
+			fmt.Printf("  %s\n", d.LastError.String)
+		}
+	}
+	return nil
+}
+
+// queueWebhooks queues new posts for the webhooks of users following the
+// feed.
+func (a *aggregator) queueWebhooks(feedID int64, posts []*database.Post) {
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	if _, err := database.EnqueueWebhookDeliveries(a.s.DB, feedID, ids); err != nil {
+		a.s.Log.Error("could not queue webhook deliveries", "feed_id", feedID, "err", err)
+	}
+}
+
+// deliveryResult is the outcome of sending one queued delivery.
+type deliveryResult struct {
+	delivery database.WebhookDelivery
+	status   int
+	err      error
+}
+
+// deliverWebhooks sends every due delivery in the queue. Requests run in a
+// small pool of workers; outcomes are recorded on the calling goroutine.
+// Failed deliveries are retried with exponential backoff by later calls.
+func (a *aggregator) deliverWebhooks(ctx context.Context) {
+	client := &http.Client{Timeout: webhookTimeout}
+	for ctx.Err() == nil {
+		due, err := database.GetDueDeliveries(a.s.DB, webhookBatch)
+		if err != nil {
+			a.s.Log.Error("could not list webhook deliveries", "err", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		jobs := make(chan database.WebhookDelivery)
+		results := make(chan deliveryResult, len(due))
+		for range min(webhookWorkers, len(due)) {
+			go func() {
+				for d := range jobs {
+					status, err := webhooks.Send(ctx, client, webhooks.Target{URL: d.URL, Format: d.Format, Secret: d.Secret}, webhookMessage(d))
+					results <- deliveryResult{d, status, err}
+				}
+			}()
+		}
+		go func() {
+			defer close(jobs)
+			for _, d := range due {
+				select {
+				case jobs <- d:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		for range due {
+			var res deliveryResult
+			select {
+			case res = <-results:
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return // interrupted, not the receiver's fault; try again next time
+			}
+			a.recordDelivery(res)
+		}
+		if len(due) < webhookBatch {
+			return
+		}
+	}
+}
+
+// recordDelivery stores the outcome of one delivery, scheduling a retry if it
+// failed and attempts remain.
+func (a *aggregator) recordDelivery(res deliveryResult) {
+	d := res.delivery
+	log := a.s.Log.With("webhook_id", d.WebhookID, "delivery_id", d.ID, "post_url", d.Post.URL)
+	if res.err == nil {
+		log.Debug("delivered webhook", "status", res.status)
+		if err := database.RecordDeliverySuccess(a.s.DB, d.ID, res.status); err != nil {
+			log.Error("could not record webhook delivery", "err", err)
+		}
+		return
+	}
+
+	attempts := d.Attempts + 1
+	var retryIn time.Duration
+	if attempts < webhookMaxAttempts {
+		retryIn = webhookBackoff(attempts)
+		log.Warn("webhook delivery failed, will retry", "attempt", attempts, "retry_in", retryIn, "err", res.err)
+	} else {
+		log.Error("webhook delivery failed, giving up", "attempts", attempts, "err", res.err)
+	}
+	if err := database.RecordDeliveryFailure(a.s.DB, d.ID, res.status, res.err.Error(), retryIn); err != nil {
+		log.Error("could not record webhook delivery", "err", err)
+	}
+}
+
+// webhookBackoff returns how long to wait before retrying a delivery that
+// has failed attempts times.
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookRetryBase
+	for i := 1; i < attempts && d < webhookRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, webhookRetryMax)
+}
+
+func webhookMessage(d database.WebhookDelivery) webhooks.Message {
+	m := webhooks.Message{
+		DeliveryID: d.ID,
+		Feed:       webhooks.Feed{Name: d.Feed.Name, URL: d.Feed.URL},
+		Post: webhooks.Post{
+			ID:          d.Post.ID,
+			Title:       d.Post.Title,
+			URL:         d.Post.URL,
+			Description: d.Post.Description.String,
+		},
+	}
+	if d.Post.PublishedAt.Valid {
+		m.Post.PublishedAt = &d.Post.PublishedAt.Time
+	}
+	return m
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"blogo/internal/database"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// followedFeed registers a user following a new feed, and returns their IDs.
+func followedFeed(t *testing.T, s *State, username, feedURL string) (userID, feedID int64) {
+	t.Helper()
+	if err := database.RegisterUser(s.DB, username); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := database.GetUserID(s.DB, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feedID, err = database.CreateFeed(s.DB, "Feed of "+username, feedURL, userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateFeedFollow(s.DB, userID, feedID); err != nil {
+		t.Fatal(err)
+	}
+	return userID, feedID
+}
+
+// ingest stores new posts in the feed and returns them with their IDs.
+func ingest(t *testing.T, s *State, feedID int64, urls ...string) []*database.Post {
+	t.Helper()
+	var posts []*database.Post
+	for _, u := range urls {
+		posts = append(posts, &database.Post{
+			Title:       "Post at " + u,
+			URL:         u,
+			PublishedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+			FeedID:      feedID,
+		})
+	}
+	res, err := database.IngestPosts(s.DB, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Inserted
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	want := []time.Duration{
+		30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, 64 * time.Minute, 128 * time.Minute, 256 * time.Minute,
+		webhookRetryMax, webhookRetryMax,
+	}
+	for i, w := range want {
+		if got := webhookBackoff(i + 1); got != w {
+			t.Errorf("after %d failures: retry in %v, want %v", i+1, got, w)
+		}
+	}
+	if got := webhookBackoff(100); got != webhookRetryMax {
+		t.Errorf("after 100 failures: retry in %v, want %v", got, webhookRetryMax)
+	}
+}
+
+func TestEnqueueWebhookDeliveriesOnce(t *testing.T) {
+	s := newTestState(t)
+	userID, feedID := followedFeed(t, s, "ann", "https://example.com/feed")
+	if _, err := database.CreateWebhook(s.DB, userID, "https://hooks.example.com/", "json", ""); err != nil {
+		t.Fatal(err)
+	}
+	posts := ingest(t, s, feedID, "https://example.com/1", "https://example.com/2")
+	ids := []int64{posts[0].ID, posts[1].ID}
+
+	for i, want := range []int64{2, 0} {
+		n, err := database.EnqueueWebhookDeliveries(s.DB, feedID, ids)
+		if err != nil || n != want {
+			t.Errorf("enqueue #%d = %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+	var n int
+	if err := s.DB.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries;`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("deliveries: %d, %v; want 2", n, err)
+	}
+}
+
+// TestDeliverWebhooksRetries checks that a delivery the receiver keeps
+// rejecting is retried with growing delays, then given up on.
+func TestDeliverWebhooksRetries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := newTestState(t)
+	userID, feedID := followedFeed(t, s, "ann", "https://example.com/feed")
+	if _, err := database.CreateWebhook(s.DB, userID, srv.URL, "json", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	post := ingest(t, s, feedID, "https://example.com/1")[0]
+	if _, err := database.EnqueueWebhookDeliveries(s.DB, feedID, []int64{post.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &aggregator{s: s}
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		a.deliverWebhooks(context.Background())
+
+		var status string
+		var attempts, lastStatus int
+		var delay int64
+		err := s.DB.QueryRow(`
+          SELECT status, attempts, last_status,
+                 unixepoch(next_attempt_at) - unixepoch(last_attempt_at)
+          FROM webhook_deliveries;
+        `).Scan(&status, &attempts, &lastStatus, &delay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != attempt || lastStatus != http.StatusServiceUnavailable {
+			t.Fatalf("after attempt %d: %d attempts recorded, last status %d", attempt, attempts, lastStatus)
+		}
+		if attempt < webhookMaxAttempts {
+			want := webhookBackoff(attempt)
+			if status != database.DeliveryPending || time.Duration(delay)*time.Second != want {
+				t.Errorf("after attempt %d: %s, retrying in %ds; want pending, retrying in %v", attempt, status, delay, want)
+			}
+		} else if status != database.DeliveryFailed {
+			t.Errorf("after attempt %d: %s, want failed", attempt, status)
+		}
+
+		// Make the retry due now.
+		database.MustExec(t, s.DB, `UPDATE webhook_deliveries SET next_attempt_at = datetime('now', '-1 second');`)
+	}
+
+	a.deliverWebhooks(context.Background())
+	if n := requests.Load(); n != webhookMaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", n, webhookMaxAttempts)
+	}
+}
@@ -2,12 +2,40 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 const configFileName = ".blogo.json"
-const defaultDBPath = "/home/dev/go/blogo/feed.db"
+const defaultDBFile = "blogo.db"
+
+// legacyDBPath was written to configs as the default db_path, though the
+// database was never opened there; it is treated as unset.
+const legacyDBPath = "/home/dev/go/blogo/feed.db"
+
+const defaultDBJournalMode = "wal"
+const defaultDBBusyTimeout = 5 * time.Second
+const defaultDBMaxOpenConns = 4
+const defaultFetchWorkers = 4
+const defaultMinPollInterval = 5 * time.Minute
+const defaultMaxPollInterval = 24 * time.Hour
+const defaultMaxFeedFailures = 10
+const defaultHostConcurrency = 2
+const defaultHostMinInterval = time.Second
+const defaultRobotsCacheTTL = 24 * time.Hour
+const defaultHistoryDays = 30
+const defaultScheduleJitter = 30 * time.Second
+const defaultLogLevel = "info"
+const defaultLogFormat = "text"
+const defaultAggPIDFile = "blogo-agg.pid"
+const defaultAggLogFile = "blogo-agg.log"
+const defaultHookTimeout = 30 * time.Second
+const defaultHookConcurrency = 4
+const defaultSMTPPort = 587
+const defaultSMTPTLS = "starttls"
 
 func getConfigFilePath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -19,9 +47,80 @@ func getConfigFilePath() (string, error) {
 }
 
 type Config struct {
-	DBPath      string `json:"db_path"`
-	CurrentUser string `json:"current_user"`
-	path        string
+	DBPath string `json:"db_path"`
+	// SQLite journal mode (wal lets readers work while the aggregator
+	// writes), how long to wait for another process's write to finish, and
+	// how many connections each blogo process may open
+	DBJournalMode  string   `json:"db_journal_mode"`
+	DBBusyTimeout  Duration `json:"db_busy_timeout"`
+	DBMaxOpenConns int      `json:"db_max_open_conns"`
+	CurrentUser    string   `json:"current_user"`
+	FetchWorkers   int      `json:"fetch_workers"` // Feeds fetched in parallel by agg
+	// Bounds for each feed's adaptive polling interval
+	MinPollInterval Duration `json:"min_poll_interval"`
+	MaxPollInterval Duration `json:"max_poll_interval"`
+	// Cron expression(s) for when agg looks for due feeds, "" to do so
+	// continuously; scheduled runs start up to ScheduleJitter late
+	Schedule       string   `json:"schedule"`
+	ScheduleJitter Duration `json:"schedule_jitter"`
+	// Consecutive failed fetches after which a feed is disabled
+	MaxFeedFailures int `json:"max_feed_failures"`
+	// Per-host politeness: parallel requests and spacing between requests
+	HostConcurrency int      `json:"host_concurrency"`
+	HostMinInterval Duration `json:"host_min_interval"`
+	// robots.txt compliance is on unless IgnoreRobots is set
+	IgnoreRobots   bool     `json:"ignore_robots"`
+	RobotsCacheTTL Duration `json:"robots_cache_ttl"`
+	// Where the running aggregator records its PID, and where `agg --daemon`
+	// sends its output
+	AggPIDFile string `json:"agg_pid_file"`
+	AggLogFile string `json:"agg_log_file"`
+	// Diagnostics written to stderr: debug, info, warn or error, as text or json
+	LogLevel  string `json:"log_level"`
+	LogFormat string `json:"log_format"`
+	// host:port for the aggregator's Prometheus /metrics listener, "" for none
+	MetricsAddr string `json:"metrics_addr"`
+	// Days of fetch history kept for `history`
+	HistoryDays int `json:"history_days"`
+	// Posts kept per feed, unless a feed overrides them with `retention`:
+	// those from the last RetainDays days and the newest RetainItems. 0 keeps
+	// everything. Starred and annotated posts are always kept.
+	RetainDays  int `json:"retain_days"`
+	RetainItems int `json:"retain_items"`
+	// Commands run for new posts stored by the aggregator, at most
+	// HookConcurrency at a time
+	Hooks           []Hook `json:"hooks,omitempty"`
+	HookConcurrency int    `json:"hook_concurrency"`
+	// Mail server for digests; digests are off while Host is empty
+	SMTP SMTP `json:"smtp"`
+	path string
+}
+
+// SMTP is how digests are sent.
+type SMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username,omitempty"` // No authentication if empty
+	Password string `json:"password,omitempty"`
+	From     string `json:"from"`
+	// "starttls" (upgrade a plain connection, required), "tls" (implicit
+	// TLS, usually port 465) or "none"
+	TLS string `json:"tls"`
+}
+
+// Hook is a shell command run for posts the aggregator stores.
+type Hook struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	// Only for posts from these feed URLs; all feeds if empty
+	Feeds []string `json:"feeds,omitempty"`
+	// Only for posts mentioning one of these in their title or
+	// description, ignoring case; all posts if empty
+	Keywords []string `json:"keywords,omitempty"`
+	// Run once per fetched feed with every matching post instead of once
+	// per post
+	Batch   bool     `json:"batch,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
 }
 
 func Read() (*Config, error) {
@@ -30,7 +129,14 @@ func Read() (*Config, error) {
 		return nil, err
 	}
 
-	cfg := &Config{path: path}
+	// "0s" is a meaningful host_min_interval and schedule_jitter, so their
+	// defaults are set before reading the file rather than filled in
+	// afterwards.
+	cfg := &Config{
+		path:            path,
+		HostMinInterval: Duration{defaultHostMinInterval},
+		ScheduleJitter:  Duration{defaultScheduleJitter},
+	}
 
 	data, err := os.ReadFile(cfg.path)
 	if err != nil && !os.IsNotExist(err) {
@@ -45,8 +151,76 @@ func Read() (*Config, error) {
 	}
 
 	// Ensure a default DBPath if none provided
-	if cfg.DBPath == "" {
-		cfg.DBPath = defaultDBPath
+	if cfg.DBPath == "" || cfg.DBPath == legacyDBPath {
+		cfg.DBPath = filepath.Join(dataDir(), "blogo", defaultDBFile)
+	}
+	if cfg.DBJournalMode == "" {
+		cfg.DBJournalMode = defaultDBJournalMode
+	}
+	if cfg.DBBusyTimeout.Duration <= 0 {
+		cfg.DBBusyTimeout.Duration = defaultDBBusyTimeout
+	}
+	if cfg.DBMaxOpenConns <= 0 {
+		cfg.DBMaxOpenConns = defaultDBMaxOpenConns
+	}
+	if cfg.FetchWorkers <= 0 {
+		cfg.FetchWorkers = defaultFetchWorkers
+	}
+	if cfg.MinPollInterval.Duration <= 0 {
+		cfg.MinPollInterval.Duration = defaultMinPollInterval
+	}
+	if cfg.MaxPollInterval.Duration <= 0 {
+		cfg.MaxPollInterval.Duration = defaultMaxPollInterval
+	}
+	if cfg.MaxFeedFailures <= 0 {
+		cfg.MaxFeedFailures = defaultMaxFeedFailures
+	}
+	if cfg.HostConcurrency <= 0 {
+		cfg.HostConcurrency = defaultHostConcurrency
+	}
+	if cfg.HostMinInterval.Duration < 0 {
+		cfg.HostMinInterval.Duration = 0
+	}
+	if cfg.ScheduleJitter.Duration < 0 {
+		cfg.ScheduleJitter.Duration = 0
+	}
+	if cfg.RobotsCacheTTL.Duration <= 0 {
+		cfg.RobotsCacheTTL.Duration = defaultRobotsCacheTTL
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = defaultLogLevel
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = defaultLogFormat
+	}
+	if cfg.HistoryDays <= 0 {
+		cfg.HistoryDays = defaultHistoryDays
+	}
+	if cfg.RetainDays < 0 {
+		cfg.RetainDays = 0
+	}
+	if cfg.RetainItems < 0 {
+		cfg.RetainItems = 0
+	}
+	if cfg.HookConcurrency <= 0 {
+		cfg.HookConcurrency = defaultHookConcurrency
+	}
+	for i := range cfg.Hooks {
+		if cfg.Hooks[i].Timeout.Duration <= 0 {
+			cfg.Hooks[i].Timeout.Duration = defaultHookTimeout
+		}
+	}
+	if cfg.SMTP.Port <= 0 {
+		cfg.SMTP.Port = defaultSMTPPort
+	}
+	if cfg.SMTP.TLS == "" {
+		cfg.SMTP.TLS = defaultSMTPTLS
+	}
+	if cfg.AggPIDFile == "" {
+		cfg.AggPIDFile = filepath.Join(runtimeDir(), defaultAggPIDFile)
+	}
+	if cfg.AggLogFile == "" {
+		cfg.AggLogFile = filepath.Join(stateDir(), defaultAggLogFile)
 	}
 
 	// On first run (file missing), or if we updated defaults, save it
@@ -58,6 +232,55 @@ func Read() (*Config, error) {
 	return cfg, nil
 }
 
+// runtimeDir returns the directory for files that only matter while blogo is
+// running, such as PID files.
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// stateDir returns the directory for logs and other files that should
+// survive restarts.
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state")
+	}
+	return os.TempDir()
+}
+
+// dataDir returns the directory for user data such as the database.
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share")
+	}
+	return "."
+}
+
+// ExpandPath expands environment variables and a leading ~ in path, and
+// makes it absolute.
+func ExpandPath(path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("expand %q: %w", path, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// write saves the config readable only by its owner, as it can hold the SMTP
+// password. A file written by an older version is tightened before the new
+// contents go in, since opening it keeps its mode.
 func (cfg *Config) write() error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
@@ -67,8 +290,23 @@ func (cfg *Config) write() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0666)
-
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 func (cfg *Config) SetUser(user string) error {
@@ -0,0 +1,242 @@
+// Package cron parses standard 5-field cron expressions and computes when
+// they next fire.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is one or more cron expressions; it fires whenever any of them
+// does.
+type Schedule struct {
+	expr  string
+	specs []spec
+}
+
+// spec is a single parsed expression. Each field is a bitset of the values
+// it matches.
+type spec struct {
+	minute, hour, dom, month, dow uint64
+	// Whether day-of-month / day-of-week start with "*". When both are
+	// restricted, a day matching either one matches (as in Vixie cron).
+	domAny, dowAny bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted as another name for Sunday.
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses one or more cron expressions separated by ";". Each is either
+// five fields (minute, hour, day of month, month, day of week) or a shortcut
+// such as @hourly or @daily.
+//
+// Fields accept "*", numbers, names (jan-dec, sun-sat), ranges ("9-17"),
+// lists ("1,15") and steps ("*/10", "0-30/5").
+func Parse(expr string) (*Schedule, error) {
+	s := &Schedule{expr: strings.TrimSpace(expr)}
+	for _, part := range strings.Split(expr, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sp, err := parseSpec(part)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", part, err)
+		}
+		s.specs = append(s.specs, sp)
+	}
+	if len(s.specs) == 0 {
+		return nil, fmt.Errorf("empty cron expression")
+	}
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func parseSpec(expr string) (spec, error) {
+	if strings.HasPrefix(expr, "@") {
+		full, ok := shortcuts[strings.ToLower(expr)]
+		if !ok {
+			return spec{}, fmt.Errorf("unknown shortcut %s", expr)
+		}
+		expr = full
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return spec{}, fmt.Errorf("want 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+
+	var sp spec
+	var err error
+	if sp.minute, err = parseField(fields[0], minuteField); err != nil {
+		return spec{}, err
+	}
+	if sp.hour, err = parseField(fields[1], hourField); err != nil {
+		return spec{}, err
+	}
+	if sp.dom, err = parseField(fields[2], domField); err != nil {
+		return spec{}, err
+	}
+	if sp.month, err = parseField(fields[3], monthField); err != nil {
+		return spec{}, err
+	}
+	if sp.dow, err = parseField(fields[4], dowField); err != nil {
+		return spec{}, err
+	}
+	if sp.dow&(1<<7) != 0 {
+		sp.dow |= 1 // Sunday
+	}
+	sp.domAny = strings.HasPrefix(fields[2], "*")
+	sp.dowAny = strings.HasPrefix(fields[4], "*")
+	return sp, nil
+}
+
+// parseField returns the set of values matched by a comma-separated field.
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				hi = f.max // "5/15" means 5, 20, 35, 50
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name within the field's bounds.
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %d out of range %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t at which the schedule fires, in t's
+// location. It returns the zero time if the schedule never fires (such as
+// "0 0 31 2 *").
+func (s *Schedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, sp := range s.specs {
+		if n := sp.next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// maxSearchYears bounds the search for impossible dates such as Feb 31.
+const maxSearchYears = 5
+
+func (sp spec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if sp.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !sp.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if sp.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if sp.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (sp spec) dayMatches(t time.Time) bool {
+	dom := sp.dom&(1<<uint(t.Day())) != 0
+	dow := sp.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case sp.domAny && sp.dowAny:
+		return true
+	case sp.domAny:
+		return dow
+	case sp.dowAny:
+		return dom
+	}
+	return dom || dow
+}
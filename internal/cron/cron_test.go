@@ -0,0 +1,125 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+const layout = "2006-01-02 15:04 Mon"
+
+func TestNext(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string // empty if the schedule never fires
+	}{
+		{"every minute", "* * * * *", "2024-01-01 10:07 Mon", "2024-01-01 10:08 Mon"},
+		{"minute", "5 * * * *", "2024-01-01 10:07 Mon", "2024-01-01 11:05 Mon"},
+		{"strictly after", "7 10 * * *", "2024-01-01 10:07 Mon", "2024-01-02 10:07 Tue"},
+		{"list", "0 0,12 * * *", "2024-01-01 10:07 Mon", "2024-01-01 12:00 Mon"},
+		{"range", "0 9-17 * * *", "2024-01-01 17:30 Mon", "2024-01-02 09:00 Tue"},
+		{"step", "*/15 * * * *", "2024-01-01 10:15 Mon", "2024-01-01 10:30 Mon"},
+		{"range with step", "0 18-23/2 * * *", "2024-01-01 20:00 Mon", "2024-01-01 22:00 Mon"},
+		{"value with step", "5/20 * * * *", "2024-01-01 10:07 Mon", "2024-01-01 10:25 Mon"},
+		{"month name", "0 0 1 jun *", "2024-01-01 10:07 Mon", "2024-06-01 00:00 Sat"},
+		{"day names", "30 8 * * mon-fri", "2024-01-05 09:00 Fri", "2024-01-08 08:30 Mon"},
+		{"upper case names", "30 8 * JAN SAT", "2024-01-01 10:07 Mon", "2024-01-06 08:30 Sat"},
+		{"sunday as 7", "0 12 * * 7", "2024-01-01 10:07 Mon", "2024-01-07 12:00 Sun"},
+		{"sunday as 0", "0 12 * * 0", "2024-01-01 10:07 Mon", "2024-01-07 12:00 Sun"},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00 Fri", "2028-02-29 00:00 Tue"},
+		{"year end", "59 23 31 12 *", "2024-12-31 23:59 Tue", "2025-12-31 23:59 Wed"},
+
+		{"@hourly", "@hourly", "2024-01-01 10:07 Mon", "2024-01-01 11:00 Mon"},
+		{"@daily", "@daily", "2024-01-01 10:07 Mon", "2024-01-02 00:00 Tue"},
+		{"@midnight", "@midnight", "2024-01-01 10:07 Mon", "2024-01-02 00:00 Tue"},
+		{"@weekly", "@weekly", "2024-01-01 10:07 Mon", "2024-01-07 00:00 Sun"},
+		{"@monthly", "@monthly", "2024-01-01 10:07 Mon", "2024-02-01 00:00 Thu"},
+		{"@yearly", "@yearly", "2024-01-01 10:07 Mon", "2025-01-01 00:00 Wed"},
+		{"@annually", "@annually", "2024-01-01 10:07 Mon", "2025-01-01 00:00 Wed"},
+		{"shortcut in upper case", "@DAILY", "2024-01-01 10:07 Mon", "2024-01-02 00:00 Tue"},
+
+		// When both day fields are restricted, either one matching is enough.
+		{"day of month or week, week first", "0 0 13 * fri", "2024-01-01 10:07 Mon", "2024-01-05 00:00 Fri"},
+		{"day of month or week, month first", "0 0 13 * fri", "2024-01-12 00:00 Fri", "2024-01-13 00:00 Sat"},
+		{"day of month only", "0 0 13 * *", "2024-01-01 10:07 Mon", "2024-01-13 00:00 Sat"},
+		{"day of week only", "0 0 * * fri", "2024-01-01 10:07 Mon", "2024-01-05 00:00 Fri"},
+		// A field starting with "*" counts as unrestricted, so only the
+		// other one applies.
+		{"starred day of month with step", "0 0 */2 * fri", "2024-01-01 10:07 Mon", "2024-01-05 00:00 Fri"},
+
+		{"several expressions", "0 9 * * *; 30 17 * * *", "2024-01-01 10:07 Mon", "2024-01-01 17:30 Mon"},
+		{"several expressions, earliest wins", "30 17 * * *;0 11 * * *", "2024-01-01 10:07 Mon", "2024-01-01 11:00 Mon"},
+
+		{"february 30", "0 0 30 2 *", "2024-01-01 10:07 Mon", ""},
+		{"april 31", "0 0 31 4 *", "2024-01-01 10:07 Mon", ""},
+		{"impossible date beside a possible one", "0 0 30 2 *; @monthly", "2024-01-01 10:07 Mon", "2024-02-01 00:00 Thu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			from, err := time.Parse(layout, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(from)
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) = %s, want never", tt.from, got.Format(layout))
+				}
+				return
+			}
+			if got.Format(layout) != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.Format(layout), tt.want)
+			}
+		})
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s, err := Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2024, 1, 1, 8, 0, 0, 0, loc))
+	if want := time.Date(2024, 1, 1, 9, 0, 0, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		" ; ",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"x * * * *",
+		"* * * xyz *",
+		"* * * * sunday",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"*/-5 * * * *",
+		"1,,2 * * * *",
+		"@reboot",
+		"@daily; @every",
+	} {
+		if s, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", expr, s.specs)
+		}
+	}
+}
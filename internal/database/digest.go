@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSubscriber is a user's digest settings.
+type DigestSubscriber struct {
+	UserID       int64
+	Username     string
+	Email        sql.NullString
+	Frequency    sql.NullString // One of the Digest* frequencies, NULL when off
+	LastDigestAt sql.NullTime   // Posts stored up to this time were already sent
+}
+
+// DigestPost is a post to include in a digest.
+type DigestPost struct {
+	Post
+	FeedName string
+}
+
+// GetDigestSubscriber returns the digest settings of a user.
+func GetDigestSubscriber(db *sql.DB, userID int64) (DigestSubscriber, error) {
+	var d DigestSubscriber
+	err := db.QueryRow(`
+      SELECT id, name, email, digest_frequency, last_digest_at
+      FROM users
+      WHERE id = ?;
+    `, userID).Scan(&d.UserID, &d.Username, &d.Email, &d.Frequency, &d.LastDigestAt)
+	if err != nil {
+		return d, fmt.Errorf("get digest settings of user %d: %w", userID, err)
+	}
+	return d, nil
+}
+
+// SetUserEmail sets or, given an invalid email, clears a user's address.
+func SetUserEmail(db *sql.DB, userID int64, email sql.NullString) error {
+	if _, err := db.Exec(`UPDATE users SET email = ? WHERE id = ?;`, email, userID); err != nil {
+		return fmt.Errorf("set email of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// SetDigestFrequency turns a user's digests on or, given an invalid
+// frequency, off. A user's first digest covers posts stored after digests
+// were turned on.
+func SetDigestFrequency(db *sql.DB, userID int64, frequency sql.NullString) error {
+	_, err := db.Exec(`
+      UPDATE users
+      SET digest_frequency = ?,
+          last_digest_at   = CASE WHEN ? IS NULL THEN NULL
+                                  ELSE COALESCE(last_digest_at, CURRENT_TIMESTAMP) END
+      WHERE id = ?;
+    `, frequency, frequency, userID)
+	if err != nil {
+		return fmt.Errorf("set digest frequency of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// GetDigestSubscribers returns the users with digests turned on and an
+// email address, ordered by name. With dueOnly set, only those whose last
+// digest is at least a day (or week) old are returned.
+func GetDigestSubscribers(db *sql.DB, dueOnly bool) ([]DigestSubscriber, error) {
+	rows, err := db.Query(`
+      SELECT id, name, email, digest_frequency, last_digest_at
+      FROM users
+      WHERE email IS NOT NULL
+        AND digest_frequency IS NOT NULL
+        AND (NOT ? OR last_digest_at IS NULL OR last_digest_at <= datetime('now',
+              CASE digest_frequency WHEN ? THEN '-7 days' ELSE '-1 days' END))
+      ORDER BY name;
+    `, dueOnly, DigestWeekly)
+	if err != nil {
+		return nil, fmt.Errorf("get digest subscribers: %w", err)
+	}
+	defer rows.Close()
+	var out []DigestSubscriber
+	for rows.Next() {
+		var d DigestSubscriber
+		if err := rows.Scan(&d.UserID, &d.Username, &d.Email, &d.Frequency, &d.LastDigestAt); err != nil {
+			return nil, fmt.Errorf("scan digest subscriber row: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate digest subscribers: %w", err)
+	}
+	return out, nil
+}
+
+// GetDigestPosts returns the posts stored after since, up to and including
+// until, in the feeds the user follows, grouped by feed name and newest
+// first within each feed. A zero since returns everything up to until.
+func GetDigestPosts(db *sql.DB, userID int64, since, until time.Time) ([]DigestPost, error) {
+	rows, err := db.Query(`
+      SELECT p.id, p.created_at, p.title, p.url, p.description, p.published_at, p.feed_id, f.name
+      FROM posts AS p
+      JOIN feed_follows AS ff ON ff.feed_id = p.feed_id
+      JOIN feeds AS f ON f.id = p.feed_id
+      WHERE ff.user_id = ?
+        AND p.created_at > ? AND p.created_at <= ?
+      ORDER BY f.name, f.id, COALESCE(p.published_at, p.created_at) DESC;
+    `, userID, sqlTime(since), sqlTime(until))
+	if err != nil {
+		return nil, fmt.Errorf("get digest posts for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+	var out []DigestPost
+	for rows.Next() {
+		var p DigestPost
+		if err := rows.Scan(&p.ID, &p.CreatedAt, &p.Title, &p.URL, &p.Description, &p.PublishedAt, &p.FeedID, &p.FeedName); err != nil {
+			return nil, fmt.Errorf("scan digest post row: %w", err)
+		}
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate digest posts: %w", err)
+	}
+	return out, nil
+}
+
+// MarkDigestSent records that the user was sent every post stored up to
+// until.
+func MarkDigestSent(db *sql.DB, userID int64, until time.Time) error {
+	if _, err := db.Exec(`UPDATE users SET last_digest_at = ? WHERE id = ?;`, sqlTime(until), userID); err != nil {
+		return fmt.Errorf("mark digest sent to user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// sqlTime formats t like CURRENT_TIMESTAMP, so it compares correctly with
+// columns that default to it.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
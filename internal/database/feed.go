@@ -3,19 +3,47 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 // FeedToFetch represents a minimal feed for fetching operations.
 type FeedToFetch struct {
-	ID  int64
-	URL string
+	ID          int64
+	URL         string
+	NextFetchAt sql.NullTime // NULL until the feed has been scheduled
 }
 
-// FeedInfo contains feed listing information, including owner username.
-type FeedInfo struct {
+// FeedHealth describes how fetching a feed has been going.
+type FeedHealth struct {
+	ConsecutiveFailures int            // Failed fetches since the last success
+	LastError           sql.NullString // Error of the most recent failed fetch
+	LastStatus          sql.NullInt64  // HTTP status of the most recent fetch
+	LastSuccessAt       sql.NullTime   // Time of the most recent successful fetch
+	DisabledAt          sql.NullTime   // Set once the feed was disabled for failing
+}
+
+// Broken reports whether the feed is failing or was disabled.
+func (h FeedHealth) Broken() bool {
+	return h.ConsecutiveFailures > 0 || h.DisabledAt.Valid
+}
+
+// BrokenFeed is a feed that is failing or disabled, with its owner.
+type BrokenFeed struct {
+	ID       int64
 	Name     string
 	URL      string
 	Username string
+	FeedHealth
+}
+
+// FeedInfo contains feed listing information, including owner username.
+type FeedInfo struct {
+	Name          string
+	URL           string
+	Username      string
+	NextFetchAt   sql.NullTime  // When the aggregator will fetch it next
+	FetchInterval time.Duration // Current polling interval, 0 if unscheduled
+	SkipReason    string        // Why the aggregator last skipped it, "" if it did not
 }
 
 // CreateFeed inserts a new feed with the given name, URL, and owner user ID.
@@ -43,9 +71,61 @@ func GetFeedByURL(db *sql.DB, url string) (id int64, name string, err error) {
 		url,
 	).Scan(&id, &name)
 	if err == sql.ErrNoRows {
-		return 0, "", fmt.Errorf("feed %q not found", url)
+		return 0, "", notFoundError(fmt.Sprintf("feed %q", url))
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("get feed %q: %w", url, err)
+	}
+	return id, name, nil
+}
+
+// GetFeedToFetch returns the feed with the given URL for an immediate fetch.
+// Returns an error if there is no such feed or it is disabled.
+func GetFeedToFetch(db *sql.DB, url string) (FeedToFetch, error) {
+	var f FeedToFetch
+	var disabled sql.NullTime
+	err := db.QueryRow(
+		`SELECT id, url, next_fetch_at, disabled_at FROM feeds WHERE url = ?;`,
+		url,
+	).Scan(&f.ID, &f.URL, &f.NextFetchAt, &disabled)
+	if err == sql.ErrNoRows {
+		return f, fmt.Errorf("feed %q not found", url)
+	}
+	if err != nil {
+		return f, fmt.Errorf("get feed %q: %w", url, err)
 	}
-	return id, name, err
+	if disabled.Valid {
+		return f, fmt.Errorf("feed %q is disabled, re-enable it with `enable` first", url)
+	}
+	return f, nil
+}
+
+// GetFeedsToFetchForUser returns the enabled feeds the user follows, ordered
+// by ID.
+func GetFeedsToFetchForUser(db *sql.DB, userID int64) ([]FeedToFetch, error) {
+	rows, err := db.Query(`
+      SELECT f.id, f.url, f.next_fetch_at
+      FROM feeds AS f
+      JOIN feed_follows AS ff ON ff.feed_id = f.id
+      WHERE ff.user_id = ? AND f.disabled_at IS NULL
+      ORDER BY f.id;
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get feeds for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+	var out []FeedToFetch
+	for rows.Next() {
+		var f FeedToFetch
+		if err := rows.Scan(&f.ID, &f.URL, &f.NextFetchAt); err != nil {
+			return nil, fmt.Errorf("scan feed row: %w", err)
+		}
+		out = append(out, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate feeds: %w", err)
+	}
+	return out, nil
 }
 
 // GetAllFeeds returns all feeds as FeedToFetch, ordered by ID.
@@ -74,7 +154,8 @@ func GetAllFeeds(db *sql.DB) ([]FeedToFetch, error) {
 // GetFeeds lists all feeds and the user who added each feed.
 func GetFeeds(db *sql.DB) ([]FeedInfo, error) {
 	rows, err := db.Query(`
-        SELECT f.name, f.url, u.name
+        SELECT f.name, f.url, u.name, f.next_fetch_at, COALESCE(f.fetch_interval, 0),
+               COALESCE(f.skip_reason, '')
         FROM feeds AS f
         JOIN users AS u ON f.user_id = u.id
         ORDER BY f.id;
@@ -86,9 +167,11 @@ func GetFeeds(db *sql.DB) ([]FeedInfo, error) {
 	var out []FeedInfo
 	for rows.Next() {
 		var fi FeedInfo
-		if err := rows.Scan(&fi.Name, &fi.URL, &fi.Username); err != nil {
+		var interval int64
+		if err := rows.Scan(&fi.Name, &fi.URL, &fi.Username, &fi.NextFetchAt, &interval, &fi.SkipReason); err != nil {
 			return nil, fmt.Errorf("scan feed row: %w", err)
 		}
+		fi.FetchInterval = time.Duration(interval) * time.Second
 		out = append(out, fi)
 	}
 	if err := rows.Err(); err != nil {
@@ -98,25 +181,80 @@ func GetFeeds(db *sql.DB) ([]FeedInfo, error) {
 }
 
 // GetNextFeedToFetch returns the next feed to fetch by fetch time priority.
-// Returns nil if no feeds are available.
+//
+// Disabled feeds are ignored. Feeds that were never scheduled come first,
+// then by next_fetch_at. Returns nil if no feeds are available.
 func GetNextFeedToFetch(db *sql.DB) (*FeedToFetch, error) {
 	const q = `
-      SELECT id, url
+      SELECT id, url, next_fetch_at
       FROM feeds
-      ORDER BY last_fetched_at IS NOT NULL, last_fetched_at ASC
+      WHERE disabled_at IS NULL
+      ORDER BY next_fetch_at IS NOT NULL, next_fetch_at ASC, last_fetched_at ASC
       LIMIT 1;
     `
 	row := db.QueryRow(q)
 	var f FeedToFetch
-	if err := row.Scan(&f.ID, &f.URL); err != nil {
+	if err := row.Scan(&f.ID, &f.URL, &f.NextFetchAt); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no feeds to fetch")
+			return nil, nil
 		}
 		return nil, err
 	}
 	return &f, nil
 }
 
+// GetDueFeeds returns the enabled feeds whose next fetch time has passed, or
+// that were never scheduled, most overdue first.
+//
+// A limit of 0 or less returns all due feeds.
+func GetDueFeeds(db *sql.DB, limit int) ([]FeedToFetch, error) {
+	const q = `
+      SELECT id, url, next_fetch_at
+      FROM feeds
+      WHERE disabled_at IS NULL
+        AND (next_fetch_at IS NULL OR next_fetch_at <= CURRENT_TIMESTAMP)
+      ORDER BY next_fetch_at IS NOT NULL, next_fetch_at ASC, id
+      LIMIT ?;
+    `
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := db.Query(q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get due feeds: %w", err)
+	}
+	defer rows.Close()
+	var out []FeedToFetch
+	for rows.Next() {
+		var f FeedToFetch
+		if err := rows.Scan(&f.ID, &f.URL, &f.NextFetchAt); err != nil {
+			return nil, fmt.Errorf("scan feed row: %w", err)
+		}
+		out = append(out, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate feeds: %w", err)
+	}
+	return out, nil
+}
+
+// CountDueFeeds counts the enabled feeds that have been due for longer than
+// lateBy; a lateBy of 0 counts every due feed. Never-scheduled feeds always
+// count.
+func CountDueFeeds(db *sql.DB, lateBy time.Duration) (int, error) {
+	var n int
+	err := db.QueryRow(`
+      SELECT COUNT(*)
+      FROM feeds
+      WHERE disabled_at IS NULL
+        AND (next_fetch_at IS NULL OR next_fetch_at <= datetime('now', ?));
+    `, fmt.Sprintf("-%d seconds", int64(lateBy/time.Second))).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count due feeds: %w", err)
+	}
+	return n, nil
+}
+
 // MarkFeedFetched updates the last_fetched_at timestamp for the given feed ID.
 func MarkFeedFetched(db *sql.DB, feedID int64) error {
 	const q = `
@@ -128,3 +266,177 @@ func MarkFeedFetched(db *sql.DB, feedID int64) error {
 	_, err := db.Exec(q, feedID)
 	return err
 }
+
+// ScheduleFeed sets the feed's polling interval and its next fetch time to
+// that interval from now.
+func ScheduleFeed(db *sql.DB, feedID int64, interval time.Duration) error {
+	secs := int64(interval / time.Second)
+	const q = `
+      UPDATE feeds
+      SET fetch_interval = ?,
+          next_fetch_at  = datetime('now', ?)
+      WHERE id = ?;
+    `
+	if _, err := db.Exec(q, secs, fmt.Sprintf("+%d seconds", secs), feedID); err != nil {
+		return fmt.Errorf("schedule feed %d: %w", feedID, err)
+	}
+	return nil
+}
+
+// DeferFeed postpones the feed's next fetch until the given time without
+// changing its polling interval.
+func DeferFeed(db *sql.DB, feedID int64, until time.Time) error {
+	secs := int64(time.Until(until).Round(time.Second) / time.Second)
+	const q = `UPDATE feeds SET next_fetch_at = datetime('now', ?) WHERE id = ?;`
+	if _, err := db.Exec(q, fmt.Sprintf("%+d seconds", secs), feedID); err != nil {
+		return fmt.Errorf("defer feed %d: %w", feedID, err)
+	}
+	return nil
+}
+
+// GetRecentPublishTimes returns the publish times of a feed's latest posts,
+// newest first. Posts without a publish time are ignored.
+func GetRecentPublishTimes(db *sql.DB, feedID int64, limit int) ([]time.Time, error) {
+	rows, err := db.Query(`
+      SELECT published_at
+      FROM posts
+      WHERE feed_id = ? AND published_at IS NOT NULL
+      ORDER BY published_at DESC
+      LIMIT ?;
+    `, feedID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get publish times for feed %d: %w", feedID, err)
+	}
+	defer rows.Close()
+	var out []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("scan publish time: %w", err)
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate publish times: %w", err)
+	}
+	return out, nil
+}
+
+// RecordFetchSuccess clears the feed's failure state after a successful
+// fetch that returned the given HTTP status.
+func RecordFetchSuccess(db *sql.DB, feedID int64, status int) error {
+	const q = `
+      UPDATE feeds
+      SET consecutive_failures = 0,
+          last_error           = NULL,
+          last_status          = ?,
+          last_success_at      = CURRENT_TIMESTAMP,
+          skip_reason          = NULL
+      WHERE id = ?;
+    `
+	if _, err := db.Exec(q, status, feedID); err != nil {
+		return fmt.Errorf("record success of feed %d: %w", feedID, err)
+	}
+	return nil
+}
+
+// RecordFetchFailure counts a failed fetch against the feed. status is the
+// HTTP status, or 0 if no response was received.
+//
+// Returns the number of consecutive failures including this one.
+func RecordFetchFailure(db *sql.DB, feedID int64, status int, msg string) (int, error) {
+	const q = `
+      UPDATE feeds
+      SET consecutive_failures = consecutive_failures + 1,
+          last_error           = ?,
+          last_status          = ?
+      WHERE id = ?
+      RETURNING consecutive_failures;
+    `
+	var failures int
+	err := db.QueryRow(q, msg, sql.NullInt64{Int64: int64(status), Valid: status != 0}, feedID).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("record failure of feed %d: %w", feedID, err)
+	}
+	return failures, nil
+}
+
+// RecordFetchSkipped notes why the aggregator chose not to fetch the feed.
+// The reason is cleared by the next successful fetch.
+func RecordFetchSkipped(db *sql.DB, feedID int64, reason string) error {
+	if _, err := db.Exec(`UPDATE feeds SET skip_reason = ? WHERE id = ?;`, reason, feedID); err != nil {
+		return fmt.Errorf("record skip of feed %d: %w", feedID, err)
+	}
+	return nil
+}
+
+// DisableFeed stops the aggregator from fetching the feed until it is
+// re-enabled.
+func DisableFeed(db *sql.DB, feedID int64) error {
+	if _, err := db.Exec(`UPDATE feeds SET disabled_at = CURRENT_TIMESTAMP WHERE id = ?;`, feedID); err != nil {
+		return fmt.Errorf("disable feed %d: %w", feedID, err)
+	}
+	return nil
+}
+
+// EnableFeed re-enables the feed with the given URL, clears its failure
+// count and last error, and makes it due for fetching immediately.
+//
+// Returns an error if the feed is not found.
+func EnableFeed(db *sql.DB, url string) error {
+	const q = `
+      UPDATE feeds
+      SET disabled_at          = NULL,
+          consecutive_failures = 0,
+          last_error           = NULL,
+          last_status          = NULL,
+          next_fetch_at        = NULL
+      WHERE url = ?;
+    `
+	res, err := db.Exec(q, url)
+	if err != nil {
+		return fmt.Errorf("enable feed %q: %w", url, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check update count: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("feed %q not found", url)
+	}
+	return nil
+}
+
+// GetBrokenFeeds lists feeds that are failing or disabled, disabled first,
+// then by number of consecutive failures.
+func GetBrokenFeeds(db *sql.DB) ([]BrokenFeed, error) {
+	rows, err := db.Query(`
+        SELECT f.id, f.name, f.url, u.name,
+               f.consecutive_failures, f.last_error, f.last_status,
+               f.last_success_at, f.disabled_at
+        FROM feeds AS f
+        JOIN users AS u ON f.user_id = u.id
+        WHERE f.consecutive_failures > 0 OR f.disabled_at IS NOT NULL
+        ORDER BY f.disabled_at IS NULL, f.consecutive_failures DESC, f.id;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("query broken feeds: %w", err)
+	}
+	defer rows.Close()
+	var out []BrokenFeed
+	for rows.Next() {
+		var bf BrokenFeed
+		if err := rows.Scan(
+			&bf.ID, &bf.Name, &bf.URL, &bf.Username,
+			&bf.ConsecutiveFailures, &bf.LastError, &bf.LastStatus,
+			&bf.LastSuccessAt, &bf.DisabledAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan feed row: %w", err)
+		}
+		out = append(out, bf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate feeds: %w", err)
+	}
+	return out, nil
+}
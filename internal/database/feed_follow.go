@@ -16,6 +16,9 @@ type FeedFollowInfo struct {
 	UserName  string    // User's name
 	FeedName  string    // Feed's name
 	FeedURL   string    // Feed's URL
+	GroupName string    // Folder the user filed the feed under, "" if none
+	FeedOwner int64     // ID of the user who added the feed
+	FeedHealth
 }
 
 // CreateFeedFollow creates a feed follow relationship for a user and feed.
@@ -38,7 +41,8 @@ func CreateFeedFollow(db *sql.DB, userID, feedID int64) (*FeedFollowInfo, error)
         SELECT ff.id, ff.created_at, ff.updated_at,
                ff.user_id, ff.feed_id,
                u.name AS user_name,
-               f.name AS feed_name, f.url AS feed_url
+               f.name AS feed_name, f.url AS feed_url,
+               COALESCE(ff.group_name, '')
         FROM feed_follows AS ff
         JOIN users AS u ON u.id = ff.user_id
         JOIN feeds AS f ON f.id = ff.feed_id
@@ -50,12 +54,45 @@ func CreateFeedFollow(db *sql.DB, userID, feedID int64) (*FeedFollowInfo, error)
 		&ff.ID, &ff.CreatedAt, &ff.UpdatedAt,
 		&ff.UserID, &ff.FeedID,
 		&ff.UserName, &ff.FeedName, &ff.FeedURL,
+		&ff.GroupName,
 	); err != nil {
 		return nil, fmt.Errorf("fetch created feed_follow: %w", err)
 	}
 	return &ff, nil
 }
 
+// IsFollowing reports whether the user follows the feed.
+func IsFollowing(db *sql.DB, userID, feedID int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM feed_follows WHERE user_id = ? AND feed_id = ?);`,
+		userID, feedID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// SetFeedFollowGroup files a followed feed under a group for the user.
+//
+// An empty group removes the feed from its group.
+// Returns an error if the user does not follow the feed.
+func SetFeedFollowGroup(db *sql.DB, userID, feedID int64, group string) error {
+	res, err := db.Exec(
+		`UPDATE feed_follows SET group_name = ? WHERE user_id = ? AND feed_id = ?;`,
+		sql.NullString{String: group, Valid: group != ""}, userID, feedID,
+	)
+	if err != nil {
+		return fmt.Errorf("set follow group: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check update count: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no follow found for user %d on feed %d", userID, feedID)
+	}
+	return nil
+}
+
 // DeleteFeedFollowByUserAndURL deletes a feed follow entry for a user and a feed URL.
 //
 // Returns an error if the feed or follow is not found, or on database error.
@@ -97,12 +134,15 @@ func GetFeedFollowsForUser(db *sql.DB, userID int64) ([]FeedFollowInfo, error) {
         SELECT ff.id, ff.created_at, ff.updated_at,
                ff.user_id, ff.feed_id,
                u.name AS user_name,
-               f.name AS feed_name, f.url AS feed_url
+               f.name AS feed_name, f.url AS feed_url,
+               COALESCE(ff.group_name, ''), f.user_id,
+               f.consecutive_failures, f.last_error, f.last_status,
+               f.last_success_at, f.disabled_at
         FROM feed_follows AS ff
         JOIN users AS u ON u.id = ff.user_id
         JOIN feeds AS f ON f.id = ff.feed_id
         WHERE ff.user_id = ?
-        ORDER BY ff.id;
+        ORDER BY ff.group_name IS NOT NULL, ff.group_name, ff.id;
     `, userID)
 	if err != nil {
 		return nil, fmt.Errorf("query feed_follows: %w", err)
@@ -116,6 +156,9 @@ func GetFeedFollowsForUser(db *sql.DB, userID int64) ([]FeedFollowInfo, error) {
 			&ff.ID, &ff.CreatedAt, &ff.UpdatedAt,
 			&ff.UserID, &ff.FeedID,
 			&ff.UserName, &ff.FeedName, &ff.FeedURL,
+			&ff.GroupName, &ff.FeedOwner,
+			&ff.ConsecutiveFailures, &ff.LastError, &ff.LastStatus,
+			&ff.LastSuccessAt, &ff.DisabledAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan feed_follow: %w", err)
 		}
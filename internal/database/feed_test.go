@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// createTestFeed adds a user and a feed of theirs.
+func createTestFeed(tb testing.TB, db *sql.DB, url string) (userID, feedID int64) {
+	tb.Helper()
+	res := MustExec(tb, db, `INSERT INTO users (name) VALUES (?);`, "owner of "+url)
+	userID, _ = res.LastInsertId()
+	feedID, err := CreateFeed(db, "Feed "+url, url, userID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return userID, feedID
+}
+
+func TestGetFeedByURL(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, feedID := createTestFeed(t, db, "https://example.com/feed")
+
+	id, name, err := GetFeedByURL(db, "https://example.com/feed")
+	if err != nil || id != feedID || name != "Feed https://example.com/feed" {
+		t.Errorf("GetFeedByURL = %d, %q, %v; want %d", id, name, err, feedID)
+	}
+
+	_, _, err = GetFeedByURL(db, "https://example.com/missing")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing feed: err = %v, want one matching sql.ErrNoRows", err)
+	}
+	if err != nil && err.Error() != `feed "https://example.com/missing" not found` {
+		t.Errorf("missing feed: err = %q", err)
+	}
+
+	db.Close()
+	if _, _, err := GetFeedByURL(db, "https://example.com/feed"); err == nil || errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("closed database: err = %v, want a failure that is not sql.ErrNoRows", err)
+	}
+}
+
+func TestEnableFeedClearsFailure(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, feedID := createTestFeed(t, db, "https://example.com/feed")
+	for range 3 {
+		if _, err := RecordFetchFailure(db, feedID, 500, "server error"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := DisableFeed(db, feedID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnableFeed(db, "https://example.com/feed"); err != nil {
+		t.Fatal(err)
+	}
+	broken, err := GetBrokenFeeds(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broken) != 0 {
+		t.Errorf("GetBrokenFeeds after enabling = %+v, want none", broken)
+	}
+	var lastError sql.NullString
+	var lastStatus sql.NullInt64
+	if err := db.QueryRow(`SELECT last_error, last_status FROM feeds WHERE id = ?;`, feedID).Scan(&lastError, &lastStatus); err != nil {
+		t.Fatal(err)
+	}
+	if lastError.Valid || lastStatus.Valid {
+		t.Errorf("last_error = %v, last_status = %v; want both cleared", lastError, lastStatus)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Fetch outcomes recorded in the fetch log.
+const (
+	FetchOK       = "ok"       // Fetched and parsed
+	FetchFailed   = "failed"   // Request or parsing failed
+	FetchSkipped  = "skipped"  // Not fetched on purpose, e.g. robots.txt
+	FetchDeferred = "deferred" // Host asked us to back off
+)
+
+// FetchRun summarises one aggregator pass over the due feeds.
+type FetchRun struct {
+	ID             int64
+	StartedAt      time.Time
+	FinishedAt     sql.NullTime // NULL while running, or if the run crashed
+	FeedsAttempted int
+	Succeeded      int
+	Failed         int
+	Skipped        int
+	NewPosts       int
+}
+
+// FetchLogEntry records one attempt to fetch a feed.
+type FetchLogEntry struct {
+	RunID         sql.NullInt64 // NULL for fetches outside an aggregator run
+	FeedID        int64
+	FetchedAt     time.Time
+	Outcome       string        // One of the Fetch* outcomes
+	Status        sql.NullInt64 // HTTP status, NULL if no response was received
+	Bytes         int64
+	Duration      time.Duration
+	ItemsSeen     int
+	ItemsInserted int
+	Error         sql.NullString
+}
+
+// StartFetchRun records the start of an aggregator run and returns its ID.
+func StartFetchRun(db *sql.DB, feedsAttempted int) (int64, error) {
+	res, err := db.Exec(`INSERT INTO fetch_runs (feeds_attempted) VALUES (?);`, feedsAttempted)
+	if err != nil {
+		return 0, fmt.Errorf("start fetch run: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("start fetch run: %w", err)
+	}
+	return id, nil
+}
+
+// FinishFetchRun stores the outcome of run r.ID and marks it finished.
+func FinishFetchRun(db *sql.DB, r FetchRun) error {
+	_, err := db.Exec(`
+      UPDATE fetch_runs
+      SET finished_at = CURRENT_TIMESTAMP,
+          succeeded   = ?,
+          failed      = ?,
+          skipped     = ?,
+          new_posts   = ?
+      WHERE id = ?;
+    `, r.Succeeded, r.Failed, r.Skipped, r.NewPosts, r.ID)
+	if err != nil {
+		return fmt.Errorf("finish fetch run %d: %w", r.ID, err)
+	}
+	return nil
+}
+
+// LogFetch appends e to the fetch log, timestamped now.
+func LogFetch(db *sql.DB, e FetchLogEntry) error {
+	_, err := db.Exec(`
+      INSERT INTO fetch_log
+        (run_id, feed_id, outcome, status, bytes, duration_ms, items_seen, items_inserted, error)
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+    `, e.RunID, e.FeedID, e.Outcome, e.Status, e.Bytes, e.Duration.Milliseconds(),
+		e.ItemsSeen, e.ItemsInserted, e.Error)
+	if err != nil {
+		return fmt.Errorf("log fetch of feed %d: %w", e.FeedID, err)
+	}
+	return nil
+}
+
+// PruneFetchHistory deletes runs and log entries older than keep.
+//
+// Returns how many rows were removed from both tables.
+func PruneFetchHistory(db *sql.DB, keep time.Duration) (int64, error) {
+	cutoff := fmt.Sprintf("-%d seconds", int64(keep/time.Second))
+	var total int64
+	for _, q := range []string{
+		`DELETE FROM fetch_log WHERE fetched_at < datetime('now', ?);`,
+		`DELETE FROM fetch_runs WHERE started_at < datetime('now', ?);`,
+	} {
+		res, err := db.Exec(q, cutoff)
+		if err != nil {
+			return total, fmt.Errorf("prune fetch history: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("prune fetch history: %w", err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// GetFetchRuns returns the most recent runs, newest first.
+func GetFetchRuns(db *sql.DB, limit int) ([]FetchRun, error) {
+	rows, err := db.Query(`
+      SELECT id, started_at, finished_at, feeds_attempted, succeeded, failed, skipped, new_posts
+      FROM fetch_runs
+      ORDER BY started_at DESC, id DESC
+      LIMIT ?;
+    `, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get fetch runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []FetchRun
+	for rows.Next() {
+		var r FetchRun
+		if err := rows.Scan(&r.ID, &r.StartedAt, &r.FinishedAt, &r.FeedsAttempted,
+			&r.Succeeded, &r.Failed, &r.Skipped, &r.NewPosts); err != nil {
+			return nil, fmt.Errorf("scan fetch run: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// GetFetchLog returns the most recent fetch attempts for a feed, newest first.
+func GetFetchLog(db *sql.DB, feedID int64, limit int) ([]FetchLogEntry, error) {
+	rows, err := db.Query(`
+      SELECT run_id, feed_id, fetched_at, outcome, status, bytes, duration_ms,
+             items_seen, items_inserted, error
+      FROM fetch_log
+      WHERE feed_id = ?
+      ORDER BY fetched_at DESC, id DESC
+      LIMIT ?;
+    `, feedID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get fetch log for feed %d: %w", feedID, err)
+	}
+	defer rows.Close()
+
+	var entries []FetchLogEntry
+	for rows.Next() {
+		var e FetchLogEntry
+		var ms int64
+		if err := rows.Scan(&e.RunID, &e.FeedID, &e.FetchedAt, &e.Outcome, &e.Status, &e.Bytes,
+			&ms, &e.ItemsSeen, &e.ItemsInserted, &e.Error); err != nil {
+			return nil, fmt.Errorf("scan fetch log: %w", err)
+		}
+		e.Duration = time.Duration(ms) * time.Millisecond
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Lock is a lease on a named resource held by one process. The holder must
+// renew it before it expires; an expired lease may be taken over.
+type Lock struct {
+	Name       string
+	Owner      string // Unique token of the holding process
+	PID        int
+	Hostname   string
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+// AcquireLock takes the named lease for ttl if it is free, expired, or
+// already held by owner.
+//
+// Returns true on success; otherwise false and the current holder.
+func AcquireLock(db *sql.DB, name, owner string, pid int, hostname string, ttl time.Duration) (bool, *Lock, error) {
+	const q = `
+      INSERT INTO locks (name, owner, pid, hostname, expires_at)
+      VALUES (?, ?, ?, ?, datetime('now', ?))
+      ON CONFLICT(name) DO UPDATE
+      SET owner       = excluded.owner,
+          pid         = excluded.pid,
+          hostname    = excluded.hostname,
+          acquired_at = CURRENT_TIMESTAMP,
+          expires_at  = excluded.expires_at
+      WHERE locks.expires_at <= CURRENT_TIMESTAMP OR locks.owner = excluded.owner;
+    `
+	res, err := db.Exec(q, name, owner, pid, hostname, leaseModifier(ttl))
+	if err != nil {
+		return false, nil, fmt.Errorf("acquire lock %q: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("check lock %q: %w", name, err)
+	}
+	if n == 1 {
+		return true, nil, nil
+	}
+	holder, err := GetLock(db, name)
+	return false, holder, err
+}
+
+// RenewLock extends owner's lease by ttl from now.
+//
+// Returns an error if owner no longer holds the lock.
+func RenewLock(db *sql.DB, name, owner string, ttl time.Duration) error {
+	res, err := db.Exec(
+		`UPDATE locks SET expires_at = datetime('now', ?) WHERE name = ? AND owner = ?;`,
+		leaseModifier(ttl), name, owner,
+	)
+	if err != nil {
+		return fmt.Errorf("renew lock %q: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check lock %q: %w", name, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("lock %q was taken over by another process", name)
+	}
+	return nil
+}
+
+// ReleaseLock removes owner's lease. Releasing a lock owner does not hold is
+// not an error, so this also clears the lease of a holder known to be dead.
+func ReleaseLock(db *sql.DB, name, owner string) error {
+	if _, err := db.Exec(`DELETE FROM locks WHERE name = ? AND owner = ?;`, name, owner); err != nil {
+		return fmt.Errorf("release lock %q: %w", name, err)
+	}
+	return nil
+}
+
+// GetLock returns the current holder of the named lease, or nil if it is
+// free or expired.
+func GetLock(db *sql.DB, name string) (*Lock, error) {
+	var l Lock
+	err := db.QueryRow(`
+      SELECT name, owner, pid, hostname, acquired_at, expires_at
+      FROM locks
+      WHERE name = ? AND expires_at > CURRENT_TIMESTAMP;
+    `, name).Scan(&l.Name, &l.Owner, &l.PID, &l.Hostname, &l.AcquiredAt, &l.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get lock %q: %w", name, err)
+	}
+	return &l, nil
+}
+
+func leaseModifier(ttl time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int64(ttl/time.Second))
+}
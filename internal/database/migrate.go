@@ -0,0 +1,344 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are pairs of files named NNNN_name.up.sql and NNNN_name.down.sql,
+// applied in order of their version number NNNN. Released migrations must
+// never be edited; change the schema by adding a new one.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of blogo than this one.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of blogo")
+
+// unversionedVersion is the number of migrations that predate the
+// migration system, when each table was made by CREATE TABLE IF NOT EXISTS
+// and columns added to a table never reached existing databases. A database
+// from that time can have any mix of their changes, depending on which
+// builds opened it; adopting it applies whatever is missing.
+const unversionedVersion = 12
+
+// Statements whose effect adoptMigration can check for.
+var (
+	addColumnPattern = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+(\w+)`)
+	createPattern    = regexp.MustCompile(`(?i)^CREATE\s+(?:TABLE|INDEX|TRIGGER)\s+(\w+)`)
+)
+
+// Migration is one step of the schema's history.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration known to this binary, the database, or both.
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt sql.NullTime // Not valid if pending
+	Unknown   bool         // Applied by a newer version of blogo
+}
+
+// Migrations returns the embedded migrations in order.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: %w", err)
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		base, dir, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		prefix, name, ok2 := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || !ok2 || err != nil || version < 1 || (dir != "up" && dir != "down") {
+			return nil, fmt.Errorf("load migrations: bad file name %q", e.Name())
+		}
+		b, err := migrationFiles.ReadFile("migrations/" + e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("load migrations: %w", err)
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("load migrations: version %d is both %q and %q", version, m.Name, name)
+		}
+		if dir == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("load migrations: missing version %d", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("load migrations: %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion returns the version of the newest migration applied to the
+// database, 0 for an empty one.
+func SchemaVersion(db *sql.DB) (int, error) {
+	if err := initMigrations(db); err != nil {
+		return 0, err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations;`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// GetMigrationStates lists every migration, applied or pending, in order.
+func GetMigrationStates(db *sql.DB) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := initMigrations(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version;`)
+	if err != nil {
+		return nil, fmt.Errorf("get migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := map[int]MigrationState{}
+	for rows.Next() {
+		var st MigrationState
+		if err := rows.Scan(&st.Version, &st.Name, &st.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scan migration: %w", err)
+		}
+		applied[st.Version] = st
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get migrations: %w", err)
+	}
+
+	var states []MigrationState
+	for _, m := range migrations {
+		st := MigrationState{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.AppliedAt = a.AppliedAt
+			delete(applied, m.Version)
+		}
+		states = append(states, st)
+	}
+	for _, a := range applied {
+		a.Unknown = true
+		states = append(states, a)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// Migrate applies every pending migration.
+//
+// Returns the migrations applied, or ErrSchemaTooNew if the database has
+// migrations this binary does not know.
+func Migrate(db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return MigrateTo(db, len(migrations))
+}
+
+// MigrateTo applies or reverts migrations, one transaction each, until the
+// database is at the target version; 0 reverts them all.
+//
+// Returns the migrations applied or reverted, in the order they ran, or
+// ErrSchemaTooNew if the database has migrations this binary does not know.
+func MigrateTo(db *sql.DB, target int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if target < 0 || target > len(migrations) {
+		return nil, fmt.Errorf("no schema version %d, the latest is %d", target, len(migrations))
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("%w (version %d, this one knows up to %d)", ErrSchemaTooNew, version, len(migrations))
+	}
+
+	var done []Migration
+	for ; version < target; version++ {
+		m := migrations[version]
+		if err := runMigration(db, m, true); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	for ; version > target; version-- {
+		m := migrations[version-1]
+		if err := runMigration(db, m, false); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// runMigration applies (up) or reverts m and records it, in one
+// transaction. Recording fails if another process got there first, which
+// rolls the step back.
+func runMigration(db *sql.DB, m Migration, up bool) error {
+	verb, script, record := "apply", m.Up, `INSERT OR IGNORE INTO schema_migrations (version, name) VALUES (?, ?);`
+	args, done := []any{m.Version, m.Name}, "applied"
+	if !up {
+		verb, script, record = "revert", m.Down, `DELETE FROM schema_migrations WHERE version = ?;`
+		args, done = args[:1], "reverted"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s migration %04d_%s: %w", verb, m.Version, m.Name, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("%s migration %04d_%s: %w", verb, m.Version, m.Name, err)
+	}
+	res, err := tx.Exec(record, args...)
+	if err != nil {
+		return fmt.Errorf("record migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return fmt.Errorf("record migration %04d_%s: already %s", m.Version, m.Name, done)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s migration %04d_%s: %w", verb, m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// initMigrations creates the schema_migrations table. A database created
+// before migrations existed is brought up to date with the migrations that
+// predate them and recorded as having them.
+func initMigrations(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("init migrations: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists, legacy bool
+	err = tx.QueryRow(`
+      SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'),
+             EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'users');
+    `).Scan(&exists, &legacy)
+	if err != nil {
+		return fmt.Errorf("init migrations: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := tx.Exec(`
+      CREATE TABLE IF NOT EXISTS schema_migrations (
+        version     INTEGER  PRIMARY KEY,
+        name        TEXT     NOT NULL,
+        applied_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+      );
+    `); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	if legacy {
+		migrations, err := Migrations()
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations[:unversionedVersion] {
+			if err := adoptMigration(tx, m); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("init migrations: %w", err)
+	}
+	return nil
+}
+
+// adoptMigration applies the statements of m that a database from before
+// migrations is missing, and records m as applied.
+func adoptMigration(tx *sql.Tx, m Migration) error {
+	for _, stmt := range statements(m.Up) {
+		var done bool
+		var err error
+		if c := addColumnPattern.FindStringSubmatch(stmt); c != nil {
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?);`, c[1], c[2]).Scan(&done)
+		} else if c := createPattern.FindStringSubmatch(stmt); c != nil {
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = ?);`, c[1]).Scan(&done)
+		}
+		if err != nil {
+			return fmt.Errorf("adopt migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if done {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("adopt migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?);`, m.Version, m.Name); err != nil {
+		return fmt.Errorf("record migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// statements splits a migration script into its statements, without the
+// comment lines between them. A statement ends with the line ending in ";",
+// or for a trigger, the line "END;".
+func statements(script string) []string {
+	var out []string
+	var cur strings.Builder
+	trigger := false
+	for _, line := range strings.Split(script, "\n") {
+		code, _, _ := strings.Cut(line, "--")
+		code = strings.TrimSpace(code)
+		if cur.Len() == 0 {
+			if code == "" {
+				continue
+			}
+			trigger = strings.HasPrefix(strings.ToUpper(code), "CREATE TRIGGER")
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if (trigger && strings.EqualFold(code, "END;")) || (!trigger && strings.HasSuffix(code, ";")) {
+			out = append(out, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		}
+	}
+	if strings.TrimSpace(cur.String()) != "" {
+		out = append(out, strings.TrimSpace(cur.String()))
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// schemaOf describes the tables, columns, indexes and triggers of db,
+// ignoring column order, which ALTER TABLE and CREATE TABLE can disagree on.
+func schemaOf(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`
+      SELECT type, name, tbl_name FROM sqlite_master
+      WHERE name NOT LIKE 'sqlite_%' AND name != 'schema_migrations';
+    `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out, tables []string
+	for rows.Next() {
+		var typ, name, table string
+		if err := rows.Scan(&typ, &name, &table); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, fmt.Sprintf("%s %s on %s", typ, name, table))
+		if typ == "table" {
+			tables = append(tables, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		cols, err := db.Query(`SELECT name, type, "notnull", COALESCE(dflt_value, ''), pk FROM pragma_table_info(?);`, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for cols.Next() {
+			var name, typ, dflt string
+			var notNull, pk int
+			if err := cols.Scan(&name, &typ, &notNull, &dflt, &pk); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, fmt.Sprintf("column %s.%s %s notnull=%d default=%q pk=%d", table, name, typ, notNull, dflt, pk))
+		}
+		cols.Close()
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestStatements(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := statements(migrations[0].Up)
+	if len(got) != 8 {
+		t.Fatalf("0001 has %d statements, want 8 (4 tables, 4 triggers):\n%s", len(got), strings.Join(got, "\n---\n"))
+	}
+	for _, stmt := range got {
+		if !createPattern.MatchString(stmt) {
+			t.Errorf("statement does not start with CREATE:\n%s", stmt)
+		}
+	}
+	if got := statements(migrations[11].Up); len(got) != 3 || !addColumnPattern.MatchString(got[2]) {
+		t.Errorf("0012 split into %q", got)
+	}
+}
+
+// TestAdoptLegacy checks that databases from before migrations, whatever
+// mix of schema changes they got, end up with the same schema as a new one.
+func TestAdoptLegacy(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := schemaOf(t, OpenMigratedTestDB(t))
+
+	tests := []struct {
+		name string
+		keep func(m Migration, stmt string) bool // statements the old builds ran
+	}{
+		{"first release", func(m Migration, _ string) bool { return m.Version == 1 }},
+		{
+			// Made by the first release, then opened by each later build:
+			// new tables appeared, but columns added to existing tables
+			// did not.
+			"first release upgraded in place",
+			func(m Migration, stmt string) bool { return m.Version == 1 || createPattern.MatchString(stmt) },
+		},
+		{
+			// Made by a build from between user-028 and user-032.
+			"intermediate build",
+			func(m Migration, _ string) bool { return m.Version <= 3 },
+		},
+		{"last release", func(Migration, string) bool { return true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := OpenTestDB(t)
+			for _, m := range migrations[:unversionedVersion] {
+				for _, stmt := range statements(m.Up) {
+					if tt.keep(m, stmt) {
+						MustExec(t, db, stmt)
+					}
+				}
+			}
+			MustExec(t, db, `INSERT INTO users (name) VALUES ('alice');`)
+
+			if _, err := Migrate(db); err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			if got := schemaOf(t, db); !reflect.DeepEqual(got, want) {
+				t.Errorf("schema differs from a new database:\ngot  %q\nwant %q", got, want)
+			}
+			if v, err := SchemaVersion(db); err != nil || v != len(migrations) {
+				t.Errorf("SchemaVersion = %d, %v; want %d", v, err, len(migrations))
+			}
+			var n int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM users;`).Scan(&n); err != nil || n != 1 {
+				t.Errorf("users: %d, %v; want the existing row kept", n, err)
+			}
+		})
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	want := schemaOf(t, db)
+	if _, err := MigrateTo(db, 0); err != nil {
+		t.Fatalf("down to 0: %v", err)
+	}
+	if got := schemaOf(t, db); len(got) != 0 {
+		t.Errorf("schema left after reverting everything: %q", got)
+	}
+	if _, err := Migrate(db); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	if got := schemaOf(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema differs after down and up:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	MustExec(t, db, `INSERT INTO schema_migrations (version, name) VALUES (1000, 'future');`)
+	if _, err := Migrate(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Migrate = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+// TestRunMigrationTwice checks that a step another process already took is
+// rolled back, with an error naming the direction.
+func TestRunMigrationTwice(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	m := Migration{Version: 1000, Name: "noop", Up: `SELECT 1;`, Down: `SELECT 1;`}
+	for _, step := range []struct {
+		up   bool
+		want string // error suffix, or empty for success
+	}{
+		{true, ""},
+		{true, "already applied"},
+		{false, ""},
+		{false, "already reverted"},
+	} {
+		err := runMigration(db, m, step.up)
+		switch {
+		case step.want == "" && err != nil:
+			t.Errorf("up=%v: %v", step.up, err)
+		case step.want != "" && (err == nil || !strings.HasSuffix(err.Error(), step.want)):
+			t.Errorf("up=%v: %v, want an error ending in %q", step.up, err, step.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+CREATE TABLE users (
+  id         INTEGER PRIMARY KEY,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  name       TEXT NOT NULL
+);
+
+CREATE TRIGGER users_updated_at
+AFTER UPDATE ON users
+FOR EACH ROW
+BEGIN
+  UPDATE users
+  SET    updated_at = CURRENT_TIMESTAMP
+  WHERE  id = OLD.id;
+END;
+
+CREATE TABLE feeds (
+  id          INTEGER PRIMARY KEY,
+  created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_fetched_at  DATETIME NULL,
+  name        TEXT    NOT NULL,
+  url         TEXT    NOT NULL UNIQUE,
+  user_id     INTEGER NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER feeds_updated_at
+  AFTER UPDATE ON feeds
+  FOR EACH ROW
+BEGIN
+  UPDATE feeds
+    SET updated_at = CURRENT_TIMESTAMP
+    WHERE id = OLD.id;
+END;
+
+CREATE TABLE feed_follows (
+  id          INTEGER PRIMARY KEY,
+  created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  user_id     INTEGER NOT NULL,
+  feed_id     INTEGER NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+  UNIQUE (user_id, feed_id) -- also serves lookups by user_id
+);
+
+CREATE TRIGGER feed_follows_updated_at
+AFTER UPDATE ON feed_follows
+FOR EACH ROW
+BEGIN
+  UPDATE feed_follows
+    SET updated_at = CURRENT_TIMESTAMP
+    WHERE id = OLD.id;
+END;
+
+CREATE TABLE posts (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at    DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at    DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  title         TEXT        NOT NULL,
+  url           TEXT        NOT NULL UNIQUE,
+  description   TEXT,
+  published_at  DATETIME,
+  feed_id       INTEGER     NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER posts_updated_at
+  AFTER UPDATE ON posts
+  FOR EACH ROW
+BEGIN
+  UPDATE posts
+    SET updated_at = CURRENT_TIMESTAMP
+    WHERE id = NEW.id;
+END;
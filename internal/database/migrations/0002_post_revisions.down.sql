@@ -0,0 +1,3 @@
+DROP TABLE post_revisions;
+ALTER TABLE posts DROP COLUMN content_hash;
+ALTER TABLE posts DROP COLUMN source_updated_at;
@@ -0,0 +1,15 @@
+ALTER TABLE posts ADD COLUMN source_updated_at DATETIME;
+ALTER TABLE posts ADD COLUMN content_hash TEXT;
+
+CREATE TABLE post_revisions (
+  id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at         DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  post_id            INTEGER  NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  title              TEXT     NOT NULL,
+  description        TEXT,
+  published_at       DATETIME,
+  source_updated_at  DATETIME,
+  content_hash       TEXT
+);
+
+CREATE INDEX post_revisions_post_id ON post_revisions(post_id);
@@ -0,0 +1 @@
+ALTER TABLE feed_follows DROP COLUMN group_name;
@@ -0,0 +1,2 @@
+-- OPML folder a followed feed was imported from.
+ALTER TABLE feed_follows ADD COLUMN group_name TEXT NULL;
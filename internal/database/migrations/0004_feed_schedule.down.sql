@@ -0,0 +1,2 @@
+ALTER TABLE feeds DROP COLUMN fetch_interval;
+ALTER TABLE feeds DROP COLUMN next_fetch_at;
@@ -0,0 +1,2 @@
+ALTER TABLE feeds ADD COLUMN next_fetch_at DATETIME NULL;
+ALTER TABLE feeds ADD COLUMN fetch_interval INTEGER NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE feeds ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN last_error TEXT NULL;
+ALTER TABLE feeds ADD COLUMN last_status INTEGER NULL;
+ALTER TABLE feeds ADD COLUMN last_success_at DATETIME NULL;
+ALTER TABLE feeds ADD COLUMN disabled_at DATETIME NULL;
@@ -0,0 +1,2 @@
+-- Why the last fetch was skipped, e.g. disallowed by robots.txt.
+ALTER TABLE feeds ADD COLUMN skip_reason TEXT NULL;
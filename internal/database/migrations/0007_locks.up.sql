@@ -0,0 +1,8 @@
+CREATE TABLE locks (
+  name        TEXT     PRIMARY KEY,
+  owner       TEXT     NOT NULL,
+  pid         INTEGER  NOT NULL,
+  hostname    TEXT     NOT NULL,
+  acquired_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at  DATETIME NOT NULL
+);
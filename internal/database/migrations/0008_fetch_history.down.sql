@@ -0,0 +1,2 @@
+DROP TABLE fetch_log;
+DROP TABLE fetch_runs;
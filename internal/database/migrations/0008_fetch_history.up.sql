@@ -0,0 +1,29 @@
+CREATE TABLE fetch_runs (
+  id               INTEGER PRIMARY KEY AUTOINCREMENT,
+  started_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  finished_at      DATETIME,
+  feeds_attempted  INTEGER  NOT NULL DEFAULT 0,
+  succeeded        INTEGER  NOT NULL DEFAULT 0,
+  failed           INTEGER  NOT NULL DEFAULT 0,
+  skipped          INTEGER  NOT NULL DEFAULT 0,
+  new_posts        INTEGER  NOT NULL DEFAULT 0
+);
+
+CREATE INDEX fetch_runs_started_at ON fetch_runs(started_at);
+
+CREATE TABLE fetch_log (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  run_id          INTEGER  REFERENCES fetch_runs(id) ON DELETE CASCADE,
+  feed_id         INTEGER  NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+  fetched_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  outcome         TEXT     NOT NULL,
+  status          INTEGER,
+  bytes           INTEGER  NOT NULL DEFAULT 0,
+  duration_ms     INTEGER  NOT NULL DEFAULT 0,
+  items_seen      INTEGER  NOT NULL DEFAULT 0,
+  items_inserted  INTEGER  NOT NULL DEFAULT 0,
+  error           TEXT
+);
+
+CREATE INDEX fetch_log_feed_id ON fetch_log(feed_id, fetched_at);
+CREATE INDEX fetch_log_fetched_at ON fetch_log(fetched_at);
@@ -0,0 +1 @@
+DROP INDEX posts_feed_id_published_at;
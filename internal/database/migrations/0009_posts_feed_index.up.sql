@@ -0,0 +1,2 @@
+-- Listing a feed's posts newest first, and joining follows to posts.
+CREATE INDEX posts_feed_id_published_at ON posts(feed_id, published_at);
@@ -0,0 +1,3 @@
+DROP TABLE post_marks;
+ALTER TABLE feeds DROP COLUMN retain_items;
+ALTER TABLE feeds DROP COLUMN retain_days;
@@ -0,0 +1,25 @@
+-- Per-feed overrides of the configured retain_days and retain_items.
+ALTER TABLE feeds ADD COLUMN retain_days INTEGER NULL;
+ALTER TABLE feeds ADD COLUMN retain_items INTEGER NULL;
+
+CREATE TABLE post_marks (
+  user_id     INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  post_id     INTEGER  NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  starred     INTEGER  NOT NULL DEFAULT 0,
+  note        TEXT,
+  PRIMARY KEY (user_id, post_id)
+);
+
+-- Pruning checks whether anyone marked a post.
+CREATE INDEX post_marks_post_id ON post_marks(post_id);
+
+CREATE TRIGGER post_marks_updated_at
+  AFTER UPDATE ON post_marks
+  FOR EACH ROW
+BEGIN
+  UPDATE post_marks
+    SET updated_at = CURRENT_TIMESTAMP
+    WHERE user_id = OLD.user_id AND post_id = OLD.post_id;
+END;
@@ -0,0 +1,29 @@
+CREATE TABLE webhooks (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  user_id     INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  url         TEXT     NOT NULL,
+  format      TEXT     NOT NULL DEFAULT 'json',
+  secret      TEXT     NOT NULL
+);
+
+CREATE INDEX webhooks_user_id ON webhooks(user_id);
+
+-- Queue of posts to send to each webhook, kept after delivery (or after
+-- giving up) as the delivery log.
+CREATE TABLE webhook_deliveries (
+  id               INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  webhook_id       INTEGER  NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  post_id          INTEGER  NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  status           TEXT     NOT NULL DEFAULT 'pending',
+  attempts         INTEGER  NOT NULL DEFAULT 0,
+  next_attempt_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_attempt_at  DATETIME,
+  last_status      INTEGER,
+  last_error       TEXT,
+  UNIQUE (webhook_id, post_id)
+);
+
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX webhook_deliveries_post_id ON webhook_deliveries(post_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN last_digest_at;
+ALTER TABLE users DROP COLUMN digest_frequency;
+ALTER TABLE users DROP COLUMN email;
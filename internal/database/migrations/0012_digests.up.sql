@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN email TEXT NULL;
+ALTER TABLE users ADD COLUMN digest_frequency TEXT NULL; -- 'daily' or 'weekly', NULL for no digests
+ALTER TABLE users ADD COLUMN last_digest_at DATETIME NULL; -- Posts stored up to this time were sent
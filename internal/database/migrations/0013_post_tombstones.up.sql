@@ -0,0 +1,8 @@
+-- URLs of pruned posts, so a feed that still lists them doesn't store them
+-- again on the next fetch.
+CREATE TABLE post_tombstones (
+  feed_id    INTEGER  NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+  url        TEXT     NOT NULL,
+  pruned_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (feed_id, url)
+);
@@ -1,47 +1,258 @@
 package database
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"time"
 )
 
 // Post represents a single post in the database.
 type Post struct {
-	ID          int64          // Post ID
-	CreatedAt   time.Time      // Time of creation
-	UpdatedAt   time.Time      // Last update time
-	Title       string         // Post title
-	URL         string         // Post URL
-	Description sql.NullString // Post description (nullable)
-	PublishedAt sql.NullTime   // Time published (nullable)
-	FeedID      int64          // Associated feed ID
+	ID              int64          // Post ID
+	CreatedAt       time.Time      // Time of creation
+	UpdatedAt       time.Time      // Last update time
+	Title           string         // Post title
+	URL             string         // Post URL
+	Description     sql.NullString // Post description (nullable)
+	PublishedAt     sql.NullTime   // Time published (nullable)
+	SourceUpdatedAt sql.NullTime   // Last edit time declared by the feed (nullable)
+	FeedID          int64          // Associated feed ID
+	Revisions       int            // Number of recorded edits
+	Starred         bool           // Starred by the user listing posts
+	Note            sql.NullString // That user's note on the post (nullable)
 }
 
-// CreatePost inserts a new post into the posts table.
+// PostRevision is a snapshot of a post's content before it was edited.
+type PostRevision struct {
+	ID              int64          // Revision ID
+	CreatedAt       time.Time      // Time the edit was detected
+	PostID          int64          // Edited post ID
+	Title           string         // Title before the edit
+	Description     sql.NullString // Description before the edit
+	PublishedAt     sql.NullTime   // Publish time before the edit
+	SourceUpdatedAt sql.NullTime   // Declared edit time before the edit
+}
+
+// IngestResult reports what IngestPosts did with a batch of posts.
+type IngestResult struct {
+	Inserted []*Post // New posts, with their IDs set
+	Updated  []*Post // Stored posts whose content changed; a revision was recorded
+	Skipped  int     // Posts unchanged, pruned, without a URL, or stored by another feed
+}
+
+// ContentHash returns a digest of the fields that make up a post's content.
+//
+// Two posts with the same URL and hash are considered identical.
+func (p *Post) ContentHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		p.Title,
+		p.Description.String,
+		formatNullTime(p.PublishedAt),
+		formatNullTime(p.SourceUpdatedAt),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339Nano)
+}
+
+// IngestPosts stores a feed's posts in a single transaction: each post is
+// inserted, or updates the feed's stored post with the same URL if its
+// content changed. If any write fails, nothing is stored.
+//
+// When an existing post is updated, its previous content is kept in
+// post_revisions. Posts stored before content hashing existed get their hash
+// filled in without recording a revision, since the old content is unknown;
+// they count as skipped, as do posts of the feed that were pruned. Posts
+// without a URL are skipped, and so are URLs another feed stored first,
+// since a URL belongs to one post.
+func IngestPosts(db *sql.DB, posts []*Post) (IngestResult, error) {
+	var res IngestResult
+	tx, err := db.Begin()
+	if err != nil {
+		return res, fmt.Errorf("ingest posts: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	lookup, err := tx.Prepare(`SELECT id, content_hash FROM posts WHERE url = ? AND feed_id = ?;`)
+	if err != nil {
+		return res, fmt.Errorf("ingest posts: prepare: %w", err)
+	}
+	defer lookup.Close()
+	pruned, err := tx.Prepare(`SELECT EXISTS (SELECT 1 FROM post_tombstones WHERE feed_id = ? AND url = ?);`)
+	if err != nil {
+		return res, fmt.Errorf("ingest posts: prepare: %w", err)
+	}
+	defer pruned.Close()
+	insert, err := tx.Prepare(`
+        INSERT INTO posts (title, url, description, published_at, source_updated_at, content_hash, feed_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT (url) DO NOTHING;
+    `)
+	if err != nil {
+		return res, fmt.Errorf("ingest posts: prepare: %w", err)
+	}
+	defer insert.Close()
+	revise, err := tx.Prepare(`
+        INSERT INTO post_revisions (post_id, title, description, published_at, source_updated_at, content_hash)
+        SELECT id, title, description, published_at, source_updated_at, content_hash
+        FROM posts WHERE id = ?;
+    `)
+	if err != nil {
+		return res, fmt.Errorf("ingest posts: prepare: %w", err)
+	}
+	defer revise.Close()
+	update, err := tx.Prepare(`
+        UPDATE posts
+        SET title = ?, description = ?, published_at = ?, source_updated_at = ?, content_hash = ?
+        WHERE id = ? AND feed_id = ?;
+    `)
+	if err != nil {
+		return res, fmt.Errorf("ingest posts: prepare: %w", err)
+	}
+	defer update.Close()
+
+	for _, p := range posts {
+		if p.URL == "" {
+			res.Skipped++
+			continue
+		}
+		hash := p.ContentHash()
+		var stored sql.NullString
+		err := lookup.QueryRow(p.URL, p.FeedID).Scan(&p.ID, &stored)
+		if err == sql.ErrNoRows {
+			var tombstone bool
+			if err := pruned.QueryRow(p.FeedID, p.URL).Scan(&tombstone); err != nil {
+				return IngestResult{}, fmt.Errorf("lookup pruned post %q: %w", p.URL, err)
+			}
+			if tombstone {
+				res.Skipped++
+				continue
+			}
+			r, err := insert.Exec(p.Title, p.URL, p.Description, p.PublishedAt, p.SourceUpdatedAt, hash, p.FeedID)
+			if err != nil {
+				return IngestResult{}, fmt.Errorf("create post %q: %w", p.URL, err)
+			}
+			if n, err := r.RowsAffected(); err != nil {
+				return IngestResult{}, fmt.Errorf("create post %q: %w", p.URL, err)
+			} else if n == 0 {
+				res.Skipped++ // stored by another feed
+				continue
+			}
+			if p.ID, err = r.LastInsertId(); err != nil {
+				return IngestResult{}, fmt.Errorf("create post %q: %w", p.URL, err)
+			}
+			res.Inserted = append(res.Inserted, p)
+			continue
+		}
+		if err != nil {
+			return IngestResult{}, fmt.Errorf("lookup post %q: %w", p.URL, err)
+		}
+		if stored.Valid && stored.String == hash {
+			res.Skipped++
+			continue
+		}
+
+		if stored.Valid {
+			if _, err := revise.Exec(p.ID); err != nil {
+				return IngestResult{}, fmt.Errorf("record revision of %q: %w", p.URL, err)
+			}
+		}
+		if _, err := update.Exec(p.Title, p.Description, p.PublishedAt, p.SourceUpdatedAt, hash, p.ID, p.FeedID); err != nil {
+			return IngestResult{}, fmt.Errorf("update post %q: %w", p.URL, err)
+		}
+		if stored.Valid {
+			res.Updated = append(res.Updated, p)
+		} else {
+			res.Skipped++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return IngestResult{}, fmt.Errorf("ingest posts: commit: %w", err)
+	}
+	return res, nil
+}
+
+// GetPostByURL returns the post stored under the given URL.
 //
-// Returns an error if the insert fails.
-func CreatePost(db *sql.DB, p *Post) error {
-	_, err := db.Exec(
-		`INSERT INTO posts (title, url, description, published_at, feed_id) VALUES (?, ?, ?, ?, ?) ON CONFLICT(url) DO NOTHING;`,
-		p.Title, p.URL, p.Description, p.PublishedAt, p.FeedID,
+// Returns an error if the post is not found.
+func GetPostByURL(db *sql.DB, url string) (*Post, error) {
+	var p Post
+	err := db.QueryRow(`
+      SELECT p.id, p.created_at, p.updated_at,
+             p.title, p.url, p.description, p.published_at, p.source_updated_at, p.feed_id,
+             (SELECT COUNT(*) FROM post_revisions AS r WHERE r.post_id = p.id)
+      FROM posts AS p
+      WHERE p.url = ?;
+    `, url).Scan(
+		&p.ID, &p.CreatedAt, &p.UpdatedAt,
+		&p.Title, &p.URL, &p.Description, &p.PublishedAt, &p.SourceUpdatedAt, &p.FeedID,
+		&p.Revisions,
 	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("post %q not found", url)
+	}
 	if err != nil {
-		return fmt.Errorf("create post %q: %w", p.Title, err)
+		return nil, fmt.Errorf("get post %q: %w", url, err)
 	}
-	return nil
+	return &p, nil
+}
+
+// GetPostRevisions returns the recorded revisions of a post, oldest first.
+func GetPostRevisions(db *sql.DB, postID int64) ([]PostRevision, error) {
+	rows, err := db.Query(`
+      SELECT id, created_at, post_id, title, description, published_at, source_updated_at
+      FROM post_revisions
+      WHERE post_id = ?
+      ORDER BY id;
+    `, postID)
+	if err != nil {
+		return nil, fmt.Errorf("get revisions for post %d: %w", postID, err)
+	}
+	defer rows.Close()
+
+	var out []PostRevision
+	for rows.Next() {
+		var r PostRevision
+		if err := rows.Scan(
+			&r.ID, &r.CreatedAt, &r.PostID,
+			&r.Title, &r.Description, &r.PublishedAt, &r.SourceUpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan revision row: %w", err)
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate revisions: %w", err)
+	}
+	return out, nil
 }
 
-// GetPostsForUser returns the latest posts for all feeds followed by a user.
+// GetPostsForUser returns the latest posts for all feeds followed by a user,
+// with the user's stars and notes.
 //
 // The limit parameter restricts the number of posts returned.
 // Returns a slice of posts, or an error if the query fails.
 func GetPostsForUser(db *sql.DB, userID int64, limit int) ([]Post, error) {
 	const q = `
       SELECT p.id, p.created_at, p.updated_at,
-             p.title, p.url, p.description, p.published_at, p.feed_id
+             p.title, p.url, p.description, p.published_at, p.source_updated_at, p.feed_id,
+             (SELECT COUNT(*) FROM post_revisions AS r WHERE r.post_id = p.id),
+             COALESCE(m.starred, 0), m.note
       FROM posts AS p
       JOIN feed_follows AS ff ON ff.feed_id = p.feed_id
+      LEFT JOIN post_marks AS m ON m.post_id = p.id AND m.user_id = ff.user_id
       WHERE ff.user_id = ?
       ORDER BY p.published_at DESC
       LIMIT ?;
@@ -57,7 +268,8 @@ func GetPostsForUser(db *sql.DB, userID int64, limit int) ([]Post, error) {
 		var p Post
 		if err := rows.Scan(
 			&p.ID, &p.CreatedAt, &p.UpdatedAt,
-			&p.Title, &p.URL, &p.Description, &p.PublishedAt, &p.FeedID,
+			&p.Title, &p.URL, &p.Description, &p.PublishedAt, &p.SourceUpdatedAt, &p.FeedID,
+			&p.Revisions, &p.Starred, &p.Note,
 		); err != nil {
 			return nil, fmt.Errorf("scan post row: %w", err)
 		}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// StarPost stars or unstars a post for a user.
+func StarPost(db *sql.DB, userID, postID int64, starred bool) error {
+	_, err := db.Exec(`
+      INSERT INTO post_marks (user_id, post_id, starred) VALUES (?, ?, ?)
+      ON CONFLICT (user_id, post_id) DO UPDATE SET starred = excluded.starred;
+    `, userID, postID, starred)
+	if err != nil {
+		return fmt.Errorf("star post %d: %w", postID, err)
+	}
+	return clearEmptyMark(db, userID, postID)
+}
+
+// AnnotatePost sets a user's note on a post. An invalid note removes it.
+func AnnotatePost(db *sql.DB, userID, postID int64, note sql.NullString) error {
+	_, err := db.Exec(`
+      INSERT INTO post_marks (user_id, post_id, note) VALUES (?, ?, ?)
+      ON CONFLICT (user_id, post_id) DO UPDATE SET note = excluded.note;
+    `, userID, postID, note)
+	if err != nil {
+		return fmt.Errorf("annotate post %d: %w", postID, err)
+	}
+	return clearEmptyMark(db, userID, postID)
+}
+
+// clearEmptyMark removes a mark that is neither starred nor annotated, so
+// that post_marks only holds posts someone wants kept.
+func clearEmptyMark(db *sql.DB, userID, postID int64) error {
+	_, err := db.Exec(`
+      DELETE FROM post_marks
+      WHERE user_id = ? AND post_id = ? AND starred = 0 AND note IS NULL;
+    `, userID, postID)
+	if err != nil {
+		return fmt.Errorf("clear mark on post %d: %w", postID, err)
+	}
+	return nil
+}
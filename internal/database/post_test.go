@@ -0,0 +1,187 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testPosts returns n new posts for feedID, with URLs starting with prefix.
+func testPosts(feedID int64, prefix string, n int) []*Post {
+	posts := make([]*Post, n)
+	published := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range posts {
+		posts[i] = &Post{
+			Title:       fmt.Sprintf("Post %d", i),
+			URL:         fmt.Sprintf("%s/%d", prefix, i),
+			Description: sql.NullString{String: "Some text about the post.", Valid: true},
+			PublishedAt: sql.NullTime{Time: published.Add(time.Duration(i) * time.Minute), Valid: true},
+			FeedID:      feedID,
+		}
+	}
+	return posts
+}
+
+// createPostPerRow stores a post the way blogo did before IngestPosts: one
+// transaction per post, with unprepared statements. Kept to benchmark
+// against.
+func createPostPerRow(db *sql.DB, p *Post) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var id int64
+	var stored sql.NullString
+	err = tx.QueryRow(`SELECT id, content_hash FROM posts WHERE url = ?;`, p.URL).Scan(&id, &stored)
+	if err != sql.ErrNoRows {
+		return err // the benchmark only stores new posts
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO posts (title, url, description, published_at, source_updated_at, content_hash, feed_id) VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		p.Title, p.URL, p.Description, p.PublishedAt, p.SourceUpdatedAt, p.ContentHash(), p.FeedID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func TestIngestPosts(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, feedID := createTestFeed(t, db, "https://example.com/feed")
+
+	posts := testPosts(feedID, "https://example.com/posts", 3)
+	res, err := IngestPosts(db, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Inserted) != 3 || len(res.Updated) != 0 || res.Skipped != 0 {
+		t.Errorf("first ingest: %d inserted, %d updated, %d skipped; want 3, 0, 0", len(res.Inserted), len(res.Updated), res.Skipped)
+	}
+
+	again := testPosts(feedID, "https://example.com/posts", 3)
+	again[1].Title = "Edited"
+	res, err = IngestPosts(db, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Inserted) != 0 || len(res.Updated) != 1 || res.Skipped != 2 {
+		t.Errorf("second ingest: %d inserted, %d updated, %d skipped; want 0, 1, 2", len(res.Inserted), len(res.Updated), res.Skipped)
+	}
+	revs, err := GetPostRevisions(db, again[1].ID)
+	if err != nil || len(revs) != 1 || revs[0].Title != "Post 1" {
+		t.Errorf("revisions of the edited post: %+v, %v; want one titled %q", revs, err, "Post 1")
+	}
+}
+
+// TestIngestPostsSharedURL checks that a URL listed by two feeds stays with
+// the feed that stored it first, instead of flipping between their versions.
+func TestIngestPostsSharedURL(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, first := createTestFeed(t, db, "https://example.com/feed")
+	_, second := createTestFeed(t, db, "https://planet.example.com/feed")
+
+	if _, err := IngestPosts(db, testPosts(first, "https://example.com/posts", 1)); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		copied := testPosts(second, "https://example.com/posts", 1)
+		copied[0].Title = "Reposted"
+		res, err := IngestPosts(db, copied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Inserted) != 0 || len(res.Updated) != 0 || res.Skipped != 1 {
+			t.Errorf("other feed: %d inserted, %d updated, %d skipped; want 0, 0, 1", len(res.Inserted), len(res.Updated), res.Skipped)
+		}
+		res, err = IngestPosts(db, testPosts(first, "https://example.com/posts", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Inserted) != 0 || len(res.Updated) != 0 || res.Skipped != 1 {
+			t.Errorf("first feed again: %d inserted, %d updated, %d skipped; want 0, 0, 1", len(res.Inserted), len(res.Updated), res.Skipped)
+		}
+	}
+	p, err := GetPostByURL(db, "https://example.com/posts/0")
+	if err != nil || p.FeedID != first || p.Title != "Post 0" || p.Revisions != 0 {
+		t.Errorf("stored post: %+v, %v; want the first feed's, unrevised", p, err)
+	}
+}
+
+func TestIngestPostsWithoutURL(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, feedID := createTestFeed(t, db, "https://example.com/feed")
+	posts := testPosts(feedID, "", 3)
+	for _, p := range posts {
+		p.URL = ""
+	}
+	res, err := IngestPosts(db, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Inserted) != 0 || res.Skipped != 3 {
+		t.Errorf("%d inserted, %d skipped; want 0, 3", len(res.Inserted), res.Skipped)
+	}
+}
+
+// BenchmarkIngestPosts stores a new 500-item feed per iteration.
+func BenchmarkIngestPosts(b *testing.B) {
+	const items = 500
+	b.Run("per-row", func(b *testing.B) {
+		db := OpenMigratedTestDB(b)
+		_, feedID := createTestFeed(b, db, "https://example.com/feed")
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, p := range testPosts(feedID, fmt.Sprintf("https://example.com/%d", i), items) {
+				if err := createPostPerRow(db, p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		db := OpenMigratedTestDB(b)
+		_, feedID := createTestFeed(b, db, "https://example.com/feed")
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := IngestPosts(db, testPosts(feedID, fmt.Sprintf("https://example.com/%d", i), items)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkGetPostsForUser lists the latest posts of a user following 10 of
+// 50 feeds, which have 100,000 posts between them.
+func BenchmarkGetPostsForUser(b *testing.B) {
+	const feeds, followed, posts = 50, 10, 100_000
+	db := OpenMigratedTestDB(b)
+	userID, _ := createTestFeed(b, db, "https://example.com/feed/0")
+	for i := 1; i < feeds; i++ {
+		if _, err := CreateFeed(db, "Feed", fmt.Sprintf("https://example.com/feed/%d", i), userID); err != nil {
+			b.Fatal(err)
+		}
+	}
+	MustExec(b, db, `INSERT INTO feed_follows (user_id, feed_id) SELECT ?, id FROM feeds ORDER BY id LIMIT ?;`, userID, followed)
+	MustExec(b, db, `
+      WITH RECURSIVE n(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM n WHERE i + 1 < ?)
+      INSERT INTO posts (title, url, description, published_at, feed_id)
+      SELECT 'Post ' || i, 'https://example.com/posts/' || i, 'Some text about the post.',
+             datetime('2024-01-01', '+' || i || ' minutes'),
+             (SELECT MIN(id) FROM feeds) + i % ? -- feed IDs are consecutive
+      FROM n;
+    `, posts, feeds)
+	MustExec(b, db, `ANALYZE;`)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		got, err := GetPostsForUser(db, userID, 20)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(got) != 20 {
+			b.Fatalf("got %d posts, want 20", len(got))
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Retention limits which posts of a feed are kept: those published in the
+// last Days days, and the newest Items. A zero limit keeps everything.
+// A post is pruned once it falls outside either limit.
+type Retention struct {
+	Days  int
+	Items int
+}
+
+// FeedRetention is a feed's own retention limits. A NULL limit falls back to
+// the global one.
+type FeedRetention struct {
+	Days  sql.NullInt64
+	Items sql.NullInt64
+}
+
+// Effective returns the limits that apply to the feed given the global ones.
+func (r FeedRetention) Effective(global Retention) Retention {
+	if r.Days.Valid {
+		global.Days = int(r.Days.Int64)
+	}
+	if r.Items.Valid {
+		global.Items = int(r.Items.Int64)
+	}
+	return global
+}
+
+// PrunedFeed counts the posts removed from one feed by PrunePosts.
+type PrunedFeed struct {
+	FeedID int64
+	Name   string
+	URL    string
+	Posts  int64
+}
+
+// GetFeedRetention returns the retention overrides of the feed with the
+// given URL.
+//
+// Returns an error if the feed is not found.
+func GetFeedRetention(db *sql.DB, url string) (FeedRetention, error) {
+	var r FeedRetention
+	err := db.QueryRow(
+		`SELECT retain_days, retain_items FROM feeds WHERE url = ?;`,
+		url,
+	).Scan(&r.Days, &r.Items)
+	if err == sql.ErrNoRows {
+		return r, fmt.Errorf("feed %q not found", url)
+	}
+	if err != nil {
+		return r, fmt.Errorf("get retention of feed %q: %w", url, err)
+	}
+	return r, nil
+}
+
+// SetFeedRetention replaces the retention overrides of the feed with the
+// given URL.
+//
+// Returns an error if the feed is not found.
+func SetFeedRetention(db *sql.DB, url string, r FeedRetention) error {
+	res, err := db.Exec(
+		`UPDATE feeds SET retain_days = ?, retain_items = ? WHERE url = ?;`,
+		r.Days, r.Items, url,
+	)
+	if err != nil {
+		return fmt.Errorf("set retention of feed %q: %w", url, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check update count: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("feed %q not found", url)
+	}
+	return nil
+}
+
+// prunableQuery selects the IDs of posts outside their feed's retention
+// limits. Posts that any user starred or annotated are never selected; they
+// still count towards a feed's newest items.
+const prunableQuery = `
+  WITH ranked AS (
+    SELECT p.id, p.feed_id,
+           datetime(COALESCE(p.published_at, p.created_at)) AS posted_at,
+           ROW_NUMBER() OVER (
+             PARTITION BY p.feed_id
+             ORDER BY datetime(COALESCE(p.published_at, p.created_at)) DESC, p.id DESC
+           ) AS position
+    FROM posts AS p
+  ),
+  limits AS (
+    SELECT id,
+           COALESCE(retain_days, ?) AS days,
+           COALESCE(retain_items, ?) AS items
+    FROM feeds
+  )
+  SELECT r.id, r.feed_id
+  FROM ranked AS r
+  JOIN limits AS l ON l.id = r.feed_id
+  WHERE ((l.days > 0 AND r.posted_at < datetime('now', printf('-%d days', l.days)))
+         OR (l.items > 0 AND r.position > l.items))
+    AND NOT EXISTS (
+      SELECT 1 FROM post_marks AS m
+      WHERE m.post_id = r.id AND (m.starred OR m.note IS NOT NULL)
+    )
+`
+
+// PrunePosts deletes the posts outside their feed's retention limits, along
+// with their revisions and webhook deliveries, in a single transaction.
+// Feeds without overrides use the global limits. With dryRun set nothing is
+// deleted, but the counts are the same.
+//
+// Each pruned post leaves a tombstone that stops IngestPosts from storing
+// it again while its feed still lists it; ForgetPrunedPosts deletes it once
+// the feed no longer does.
+//
+// Returns how many posts were (or would be) removed from each feed, for
+// feeds that lost any.
+func PrunePosts(db *sql.DB, global Retention, dryRun bool) ([]PrunedFeed, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("prune posts: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DROP TABLE IF EXISTS temp.pruned_posts;`); err != nil {
+		return nil, fmt.Errorf("prune posts: %w", err)
+	}
+	if _, err := tx.Exec(`CREATE TEMP TABLE pruned_posts AS `+prunableQuery+`;`, global.Days, global.Items); err != nil {
+		return nil, fmt.Errorf("prune posts: select posts: %w", err)
+	}
+
+	rows, err := tx.Query(`
+      SELECT f.id, f.name, f.url, COUNT(*)
+      FROM temp.pruned_posts AS pp
+      JOIN feeds AS f ON f.id = pp.feed_id
+      GROUP BY f.id
+      ORDER BY f.name;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("prune posts: count: %w", err)
+	}
+	var out []PrunedFeed
+	for rows.Next() {
+		var pf PrunedFeed
+		if err := rows.Scan(&pf.FeedID, &pf.Name, &pf.URL, &pf.Posts); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan pruned feed row: %w", err)
+		}
+		out = append(out, pf)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pruned feeds: %w", err)
+	}
+
+	if !dryRun {
+		for _, q := range []string{
+			`INSERT OR IGNORE INTO post_tombstones (feed_id, url) SELECT feed_id, url FROM posts WHERE id IN (SELECT id FROM temp.pruned_posts);`,
+			`DELETE FROM post_revisions WHERE post_id IN (SELECT id FROM temp.pruned_posts);`,
+			`DELETE FROM post_marks WHERE post_id IN (SELECT id FROM temp.pruned_posts);`,
+			`DELETE FROM webhook_deliveries WHERE post_id IN (SELECT id FROM temp.pruned_posts);`,
+			`DELETE FROM posts WHERE id IN (SELECT id FROM temp.pruned_posts);`,
+		} {
+			if _, err := tx.Exec(q); err != nil {
+				return nil, fmt.Errorf("prune posts: %w", err)
+			}
+		}
+	}
+	if _, err := tx.Exec(`DROP TABLE temp.pruned_posts;`); err != nil {
+		return nil, fmt.Errorf("prune posts: %w", err)
+	}
+	if dryRun {
+		return out, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("prune posts: commit: %w", err)
+	}
+	return out, nil
+}
+
+// ForgetPrunedPosts deletes the feed's tombstones for pruned posts whose URL
+// is not in listed, the URLs of the feed's latest fetch: once a feed stops
+// listing a post it cannot bring it back.
+//
+// Returns how many tombstones were deleted.
+func ForgetPrunedPosts(db *sql.DB, feedID int64, listed []string) (int64, error) {
+	query := `DELETE FROM post_tombstones WHERE feed_id = ?`
+	args := []any{feedID}
+	if len(listed) > 0 {
+		query += ` AND url NOT IN (?` + strings.Repeat(", ?", len(listed)-1) + `)`
+		for _, u := range listed {
+			args = append(args, u)
+		}
+	}
+	res, err := db.Exec(query+`;`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("forget pruned posts of feed %d: %w", feedID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("forget pruned posts of feed %d: %w", feedID, err)
+	}
+	return n, nil
+}
+
+// Vacuum rebuilds the database file, returning the space freed by deleted
+// rows to the filesystem. Rebuilding also applies the connection's
+// auto_vacuum setting to a database created without it; blogo's connection
+// string (dsn in main.go) sets _auto_vacuum=incremental, so that is what
+// switches older databases over to incremental vacuuming. Opened without
+// it, the database keeps its current mode.
+func Vacuum(db *sql.DB) error {
+	if _, err := db.Exec(`VACUUM;`); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
+
+// IncrementalVacuum returns free pages to the filesystem without rebuilding
+// the database. It does nothing unless the database uses incremental
+// auto-vacuum.
+func IncrementalVacuum(db *sql.DB) error {
+	if _, err := db.Exec(`PRAGMA incremental_vacuum;`); err != nil {
+		return fmt.Errorf("incremental vacuum: %w", err)
+	}
+	return nil
+}
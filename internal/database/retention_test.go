@@ -0,0 +1,63 @@
+package database
+
+import "testing"
+
+// TestPrunedPostsStayPruned checks that posts pruned from a feed are not
+// stored again when the feed still lists them.
+func TestPrunedPostsStayPruned(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, feedID := createTestFeed(t, db, "https://example.com/feed")
+	if _, err := IngestPosts(db, testPosts(feedID, "https://example.com/posts", 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := PrunePosts(db, Retention{Items: 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].Posts != 3 {
+		t.Fatalf("PrunePosts = %+v, want 3 posts pruned from one feed", pruned)
+	}
+
+	res, err := IngestPosts(db, testPosts(feedID, "https://example.com/posts", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Inserted) != 0 || len(res.Updated) != 0 || res.Skipped != 5 {
+		t.Errorf("re-ingest: %d inserted, %d updated, %d skipped; want 0, 0, 5", len(res.Inserted), len(res.Updated), res.Skipped)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM posts;`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("posts stored: %d, %v; want 2", n, err)
+	}
+}
+
+func TestForgetPrunedPosts(t *testing.T) {
+	db := OpenMigratedTestDB(t)
+	_, feedID := createTestFeed(t, db, "https://example.com/feed")
+	posts := testPosts(feedID, "https://example.com/posts", 5)
+	if _, err := IngestPosts(db, posts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PrunePosts(db, Retention{Items: 2}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The feed dropped post 0, which was pruned, and still lists the rest.
+	var listed []string
+	for _, p := range posts[1:] {
+		listed = append(listed, p.URL)
+	}
+	n, err := ForgetPrunedPosts(db, feedID, listed)
+	if err != nil || n != 1 {
+		t.Errorf("ForgetPrunedPosts = %d, %v; want 1", n, err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM post_tombstones;`).Scan(&left); err != nil || left != 2 {
+		t.Errorf("tombstones left: %d, %v; want 2", left, err)
+	}
+
+	if n, err := ForgetPrunedPosts(db, feedID, nil); err != nil || n != 2 {
+		t.Errorf("empty feed: ForgetPrunedPosts = %d, %v; want 2", n, err)
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Helpers for the tests of this and other packages that need a database.
+
+// OpenTestDB returns an empty database in a temporary directory, opened
+// the way blogo opens its own, and closes it when the test ends.
+func OpenTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "blogo.db")
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_foreign_keys=on")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// OpenMigratedTestDB returns a test database with the full schema.
+func OpenMigratedTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db := OpenTestDB(tb)
+	if _, err := Migrate(db); err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// MustExec runs statements that are expected to succeed, failing the test
+// otherwise.
+func MustExec(tb testing.TB, db *sql.DB, query string, args ...any) sql.Result {
+	tb.Helper()
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		tb.Fatalf("%s: %v", query, err)
+	}
+	return res
+}
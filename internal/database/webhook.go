@@ -0,0 +1,265 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"   // Not sent yet, or waiting for a retry
+	DeliveryDelivered = "delivered" // Accepted by the receiver
+	DeliveryFailed    = "failed"    // Given up after too many attempts
+)
+
+// Webhook is a URL a user wants new posts from the feeds they follow sent to.
+type Webhook struct {
+	ID        int64
+	CreatedAt time.Time
+	UserID    int64
+	URL       string
+	Format    string // Payload format: json, slack, discord or matrix
+	Secret    string // Key for signing payloads
+	Pending   int    // Deliveries waiting to be sent
+	Failed    int    // Deliveries given up on
+}
+
+// WebhookDelivery is one post queued for, or sent to, one webhook.
+type WebhookDelivery struct {
+	ID            int64
+	CreatedAt     time.Time
+	WebhookID     int64
+	Status        string // One of the Delivery* states
+	Attempts      int
+	NextAttemptAt time.Time
+	LastAttemptAt sql.NullTime
+	LastStatus    sql.NullInt64 // HTTP status of the last attempt, NULL if no response
+	LastError     sql.NullString
+
+	// What to send, and where
+	URL    string
+	Format string
+	Secret string
+	Post   Post
+	Feed   FeedInfo // Name and URL only
+}
+
+// CreateWebhook adds a webhook for the user and returns its ID.
+func CreateWebhook(db *sql.DB, userID int64, url, format, secret string) (int64, error) {
+	res, err := db.Exec(
+		`INSERT INTO webhooks (user_id, url, format, secret) VALUES (?, ?, ?, ?);`,
+		userID, url, format, secret,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("create webhook %q: %w", url, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("retrieve new webhook ID: %w", err)
+	}
+	return id, nil
+}
+
+// GetWebhooksForUser lists the user's webhooks with their queue counts,
+// oldest first.
+func GetWebhooksForUser(db *sql.DB, userID int64) ([]Webhook, error) {
+	rows, err := db.Query(`
+      SELECT w.id, w.created_at, w.user_id, w.url, w.format, w.secret,
+             (SELECT COUNT(*) FROM webhook_deliveries AS d WHERE d.webhook_id = w.id AND d.status = ?),
+             (SELECT COUNT(*) FROM webhook_deliveries AS d WHERE d.webhook_id = w.id AND d.status = ?)
+      FROM webhooks AS w
+      WHERE w.user_id = ?
+      ORDER BY w.id;
+    `, DeliveryPending, DeliveryFailed, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get webhooks for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+	var out []Webhook
+	for rows.Next() {
+		var w Webhook
+		if err := rows.Scan(&w.ID, &w.CreatedAt, &w.UserID, &w.URL, &w.Format, &w.Secret, &w.Pending, &w.Failed); err != nil {
+			return nil, fmt.Errorf("scan webhook row: %w", err)
+		}
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhooks: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteWebhook removes one of the user's webhooks and its deliveries.
+//
+// Returns an error if the user has no webhook with that ID.
+func DeleteWebhook(db *sql.DB, userID, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("delete webhook %d: %w", id, err)
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM webhooks WHERE id = ? AND user_id = ?;`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete webhook %d: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check delete count: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("webhook %d not found", id)
+	}
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?;`, id); err != nil {
+		return fmt.Errorf("delete deliveries of webhook %d: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete webhook %d: %w", id, err)
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries queues the given new posts of a feed for every
+// webhook whose owner follows the feed.
+//
+// Returns how many deliveries were queued.
+func EnqueueWebhookDeliveries(db *sql.DB, feedID int64, postIDs []int64) (int64, error) {
+	if len(postIDs) == 0 {
+		return 0, nil
+	}
+	args := []any{feedID}
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+	res, err := db.Exec(`
+      INSERT OR IGNORE INTO webhook_deliveries (webhook_id, post_id)
+      SELECT w.id, p.id
+      FROM webhooks AS w
+      JOIN feed_follows AS ff ON ff.user_id = w.user_id AND ff.feed_id = ?
+      JOIN posts AS p ON p.feed_id = ff.feed_id
+      WHERE p.id IN (?`+strings.Repeat(", ?", len(postIDs)-1)+`)
+      ORDER BY w.id, p.id;
+    `, args...)
+	if err != nil {
+		return 0, fmt.Errorf("queue webhook deliveries for feed %d: %w", feedID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("queue webhook deliveries for feed %d: %w", feedID, err)
+	}
+	return n, nil
+}
+
+// GetDueDeliveries returns up to limit pending deliveries whose next attempt
+// is due, oldest first, with everything needed to send them.
+func GetDueDeliveries(db *sql.DB, limit int) ([]WebhookDelivery, error) {
+	return queryDeliveries(db, `
+      WHERE d.status = ? AND d.next_attempt_at <= CURRENT_TIMESTAMP
+      ORDER BY d.id
+      LIMIT ?;
+    `, DeliveryPending, limit)
+}
+
+// GetDeliveryLog returns the user's most recent deliveries, newest first,
+// optionally only those of one webhook.
+func GetDeliveryLog(db *sql.DB, userID int64, webhookID sql.NullInt64, limit int) ([]WebhookDelivery, error) {
+	return queryDeliveries(db, `
+      WHERE w.user_id = ? AND (? IS NULL OR w.id = ?)
+      ORDER BY d.id DESC
+      LIMIT ?;
+    `, userID, webhookID, webhookID, limit)
+}
+
+func queryDeliveries(db *sql.DB, where string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := db.Query(`
+      SELECT d.id, d.created_at, d.webhook_id, d.status, d.attempts, d.next_attempt_at,
+             d.last_attempt_at, d.last_status, d.last_error,
+             w.url, w.format, w.secret,
+             p.id, p.title, p.url, p.description, p.published_at, p.feed_id,
+             f.name, f.url
+      FROM webhook_deliveries AS d
+      JOIN webhooks AS w ON w.id = d.webhook_id
+      JOIN posts AS p ON p.id = d.post_id
+      JOIN feeds AS f ON f.id = p.feed_id
+    `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+	var out []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(
+			&d.ID, &d.CreatedAt, &d.WebhookID, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.LastStatus, &d.LastError,
+			&d.URL, &d.Format, &d.Secret,
+			&d.Post.ID, &d.Post.Title, &d.Post.URL, &d.Post.Description, &d.Post.PublishedAt, &d.Post.FeedID,
+			&d.Feed.Name, &d.Feed.URL,
+		); err != nil {
+			return nil, fmt.Errorf("scan delivery row: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate deliveries: %w", err)
+	}
+	return out, nil
+}
+
+// RecordDeliverySuccess marks a delivery as accepted by the receiver.
+func RecordDeliverySuccess(db *sql.DB, id int64, status int) error {
+	_, err := db.Exec(`
+      UPDATE webhook_deliveries
+      SET status          = ?,
+          attempts        = attempts + 1,
+          last_attempt_at = CURRENT_TIMESTAMP,
+          last_status     = ?,
+          last_error      = NULL
+      WHERE id = ?;
+    `, DeliveryDelivered, status, id)
+	if err != nil {
+		return fmt.Errorf("record delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// RecordDeliveryFailure notes a failed attempt. The delivery is retried
+// after retryIn, or given up on if retryIn is zero.
+func RecordDeliveryFailure(db *sql.DB, id int64, status int, msg string, retryIn time.Duration) error {
+	state := DeliveryPending
+	if retryIn <= 0 {
+		state = DeliveryFailed
+	}
+	_, err := db.Exec(`
+      UPDATE webhook_deliveries
+      SET status          = ?,
+          attempts        = attempts + 1,
+          next_attempt_at = datetime('now', ?),
+          last_attempt_at = CURRENT_TIMESTAMP,
+          last_status     = ?,
+          last_error      = ?
+      WHERE id = ?;
+    `, state, leaseModifier(retryIn), sql.NullInt64{Int64: int64(status), Valid: status != 0}, msg, id)
+	if err != nil {
+		return fmt.Errorf("record delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries deletes finished deliveries older than keep.
+// Pending deliveries are kept however old they are.
+func PruneWebhookDeliveries(db *sql.DB, keep time.Duration) (int64, error) {
+	res, err := db.Exec(`
+      DELETE FROM webhook_deliveries
+      WHERE status != ? AND created_at < datetime('now', ?);
+    `, DeliveryPending, fmt.Sprintf("-%d seconds", int64(keep/time.Second)))
+	if err != nil {
+		return 0, fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,115 @@
+// Package digest renders a user's new posts as an email.
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Digest is the new posts of one user's followed feeds since their last
+// digest.
+type Digest struct {
+	Username string
+	Since    time.Time // Zero for a first digest
+	Feeds    []Feed
+}
+
+// Feed is one feed's new posts, newest first.
+type Feed struct {
+	Name  string
+	Posts []Post
+}
+
+type Post struct {
+	Title       string
+	URL         string
+	Description string
+	PublishedAt time.Time // Zero if unknown
+}
+
+// Count returns the number of posts in the digest.
+func (d Digest) Count() int {
+	n := 0
+	for _, f := range d.Feeds {
+		n += len(f.Posts)
+	}
+	return n
+}
+
+// Subject returns the email subject line.
+func (d Digest) Subject() string {
+	return fmt.Sprintf("blogo: %d new post(s) from %d feed(s)", d.Count(), len(d.Feeds))
+}
+
+var funcs = map[string]any{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("Mon, 02 Jan 2006 15:04")
+	},
+	"summary": summary,
+}
+
+var textTemplate = texttemplate.Must(texttemplate.New("text").Funcs(funcs).Parse(
+	`Hi {{.Username}},
+
+{{with .Since | date}}New posts since {{.}}:{{else}}New posts:{{end}}
+{{range .Feeds}}
+== {{.Name}} ==
+{{range .Posts}}
+* {{.Title}}{{with .PublishedAt | date}} ({{.}}){{end}}
+  {{.URL}}{{with .Description | summary}}
+  {{.}}{{end}}
+{{end}}{{end}}
+--
+Sent by blogo. Change how often you get this with ` + "`blogo digest every daily|weekly|off`" + `.
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; max-width: 40em;">
+<p>Hi {{.Username}},</p>
+<p>{{with .Since | date}}New posts since {{.}}:{{else}}New posts:{{end}}</p>
+{{range .Feeds}}
+<h2 style="font-size: 1.2em; border-bottom: 1px solid #ccc;">{{.Name}}</h2>
+<ul>
+{{range .Posts}}<li style="margin-bottom: 0.8em;">
+<a href="{{.URL}}"><b>{{.Title}}</b></a>{{with .PublishedAt | date}} <small style="color: #666;">{{.}}</small>{{end}}
+{{with .Description | summary}}<br>{{.}}{{end}}
+</li>
+{{end}}</ul>
+{{end}}
+<p style="color: #666; font-size: small;">Sent by blogo. Change how often you get this with <code>blogo digest every daily|weekly|off</code>.</p>
+</body>
+</html>
+`))
+
+// Render returns the plain-text and HTML bodies of the digest.
+func Render(d Digest) (text, html string, err error) {
+	var tb, hb bytes.Buffer
+	if err := textTemplate.Execute(&tb, d); err != nil {
+		return "", "", fmt.Errorf("render digest: %w", err)
+	}
+	if err := htmlTemplate.Execute(&hb, d); err != nil {
+		return "", "", fmt.Errorf("render digest: %w", err)
+	}
+	return tb.String(), hb.String(), nil
+}
+
+// summaryLength bounds each post's description in the digest.
+const summaryLength = 200
+
+// summary shortens a description to one line of at most summaryLength
+// characters.
+func summary(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > summaryLength {
+		s = string(r[:summaryLength-1]) + "…"
+	}
+	return s
+}
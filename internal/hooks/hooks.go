@@ -0,0 +1,201 @@
+// Package hooks runs user-configured shell commands for newly stored posts.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook is a command to run for new posts.
+type Hook struct {
+	Name    string
+	Command string // Run by the shell
+	// Only posts from these feed URLs, or any feed when empty
+	Feeds []string
+	// Only posts whose title or description contains one of these,
+	// ignoring case, or any post when empty
+	Keywords []string
+	// Run once per fetched feed with all matching posts, rather than once
+	// per post
+	Batch   bool
+	Timeout time.Duration
+}
+
+// Post is a newly stored post, as handed to hooks.
+type Post struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	FeedID      int64      `json:"feed_id"`
+	FeedURL     string     `json:"feed_url"`
+	FeedTitle   string     `json:"feed_title"`
+}
+
+// Runner runs hooks in the background, at most a fixed number at a time.
+type Runner struct {
+	hooks []Hook
+	log   *slog.Logger
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewRunner returns a runner for the given hooks that runs at most
+// concurrency commands at once. Hooks without a command are left out.
+func NewRunner(hooks []Hook, concurrency int, log *slog.Logger) *Runner {
+	r := &Runner{log: log, slots: make(chan struct{}, max(concurrency, 1))}
+	for _, h := range hooks {
+		if strings.TrimSpace(h.Command) == "" {
+			log.Warn("ignoring hook without a command", "hook", h.Name)
+			continue
+		}
+		r.hooks = append(r.hooks, h)
+	}
+	return r
+}
+
+// Dispatch starts every hook that matches the posts, which must all come
+// from the same feed, and returns without waiting for them. Commands still
+// waiting for a slot when ctx ends are not started; running ones are killed.
+func (r *Runner) Dispatch(ctx context.Context, posts []Post) {
+	for _, h := range r.hooks {
+		var matched []Post
+		for _, p := range posts {
+			if h.matches(p) {
+				matched = append(matched, p)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if h.Batch {
+			r.start(ctx, h, matched)
+			continue
+		}
+		for _, p := range matched {
+			r.start(ctx, h, []Post{p})
+		}
+	}
+}
+
+// Wait blocks until every dispatched command has finished.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) start(ctx context.Context, h Hook, posts []Post) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		select {
+		case r.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-r.slots }()
+		r.run(ctx, h, posts)
+	}()
+}
+
+// run runs one hook. A single post is passed as a JSON object on stdin and
+// in BLOGO_POST_* variables; a batch is passed as a JSON array, with
+// BLOGO_POST_COUNT set instead. Both get the BLOGO_FEED_* variables.
+func (r *Runner) run(ctx context.Context, h Hook, posts []Post) {
+	var input any = posts
+	env := append(os.Environ(),
+		"BLOGO_HOOK="+h.Name,
+		"BLOGO_FEED_ID="+strconv.FormatInt(posts[0].FeedID, 10),
+		"BLOGO_FEED_URL="+posts[0].FeedURL,
+		"BLOGO_FEED_TITLE="+posts[0].FeedTitle,
+	)
+	if h.Batch {
+		env = append(env, "BLOGO_POST_COUNT="+strconv.Itoa(len(posts)))
+	} else {
+		p := posts[0]
+		input = p
+		env = append(env,
+			"BLOGO_POST_ID="+strconv.FormatInt(p.ID, 10),
+			"BLOGO_POST_TITLE="+p.Title,
+			"BLOGO_POST_URL="+p.URL,
+			"BLOGO_POST_DESCRIPTION="+p.Description,
+		)
+		if p.PublishedAt != nil {
+			env = append(env, "BLOGO_POST_PUBLISHED_AT="+p.PublishedAt.Format(time.RFC3339))
+		}
+	}
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		r.log.Error("could not encode posts for hook", "hook", h.Name, "err", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, h.Command)
+	killGroupOnCancel(cmd)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(stdin)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// Don't wait forever on children that keep the output open.
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	log := r.log.With("hook", h.Name, "posts", len(posts), "duration", time.Since(start).Round(time.Millisecond))
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Warn("hook timed out", "timeout", h.Timeout, "output", tail(out.String()))
+	case err != nil:
+		log.Warn("hook failed", "err", err, "output", tail(out.String()))
+	default:
+		log.Debug("hook finished", "output", tail(out.String()))
+	}
+}
+
+func (h Hook) matches(p Post) bool {
+	if len(h.Feeds) > 0 && !slices.Contains(h.Feeds, p.FeedURL) {
+		return false
+	}
+	if len(h.Keywords) == 0 {
+		return true
+	}
+	text := strings.ToLower(p.Title + "\n" + p.Description)
+	for _, k := range h.Keywords {
+		if k != "" && strings.Contains(text, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// maxLoggedOutput bounds how much of a hook's output ends up in the log.
+const maxLoggedOutput = 500
+
+// tail returns the end of a command's output for logging.
+func tail(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > maxLoggedOutput {
+		s = "…" + s[len(s)-maxLoggedOutput:]
+	}
+	return s
+}
@@ -0,0 +1,198 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingCommand returns a shell command that saves its stdin and BLOGO_*
+// variables to dir, in files named after $BLOGO_POST_ID (or "batch").
+func recordingCommand(t *testing.T, dir string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands here are sh scripts")
+	}
+	return `name=${BLOGO_POST_ID:-batch}; cat > '` + dir + `'/"$name".json; env | grep '^BLOGO_' | sort > '` + dir + `'/"$name".env`
+}
+
+func testPosts() []Post {
+	published := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	feed := func(p Post) Post {
+		p.FeedID, p.FeedURL, p.FeedTitle = 7, "https://example.com/feed", "Example"
+		return p
+	}
+	return []Post{
+		feed(Post{ID: 1, Title: "Go 1.23 released", URL: "https://example.com/go", Description: "Iterators & more", PublishedAt: &published}),
+		feed(Post{ID: 2, Title: "Gardening", URL: "https://example.com/garden"}),
+		feed(Post{ID: 3, Title: "Weekly notes", URL: "https://example.com/notes", Description: "Mostly about GO"}),
+	}
+}
+
+// readEnv parses a file of NAME=value lines.
+func readEnv(t *testing.T, path string) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		k, v, _ := strings.Cut(line, "=")
+		env[k] = v
+	}
+	return env
+}
+
+func TestMatches(t *testing.T) {
+	post := Post{Title: "Go 1.23 released", Description: "Iterators", FeedURL: "https://example.com/feed"}
+	tests := []struct {
+		name string
+		hook Hook
+		want bool
+	}{
+		{"no filters", Hook{}, true},
+		{"feed listed", Hook{Feeds: []string{"https://other.example.com/feed", "https://example.com/feed"}}, true},
+		{"feed not listed", Hook{Feeds: []string{"https://other.example.com/feed"}}, false},
+		{"keyword in title, other case", Hook{Keywords: []string{"GO"}}, true},
+		{"keyword in description", Hook{Keywords: []string{"iterator"}}, true},
+		{"one of several keywords", Hook{Keywords: []string{"rust", "released"}}, true},
+		{"no keyword", Hook{Keywords: []string{"rust"}}, false},
+		{"empty keyword matches nothing", Hook{Keywords: []string{""}}, false},
+		{"feed and keyword", Hook{Feeds: []string{"https://example.com/feed"}, Keywords: []string{"rust"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hook.matches(post); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDispatchPerPost checks that a hook runs once for each matching post,
+// with the post as JSON on stdin and in BLOGO_* variables.
+func TestDispatchPerPost(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner([]Hook{{
+		Name:     "go-posts",
+		Command:  recordingCommand(t, dir),
+		Keywords: []string{"go"},
+		Timeout:  10 * time.Second,
+	}}, 2, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	posts := testPosts()
+	r.Dispatch(context.Background(), posts)
+	r.Wait()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"1.env", "1.json", "3.env", "3.json"}; strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("hook wrote %q, want %q", names, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Post
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("stdin %s: %v", data, err)
+	}
+	want := posts[0]
+	if got.ID != want.ID || got.Title != want.Title || got.URL != want.URL || got.Description != want.Description ||
+		got.PublishedAt == nil || !got.PublishedAt.Equal(*want.PublishedAt) ||
+		got.FeedID != want.FeedID || got.FeedURL != want.FeedURL || got.FeedTitle != want.FeedTitle {
+		t.Errorf("stdin %s, want %+v", data, want)
+	}
+
+	env := readEnv(t, filepath.Join(dir, "1.env"))
+	for k, v := range map[string]string{
+		"BLOGO_HOOK":              "go-posts",
+		"BLOGO_FEED_ID":           "7",
+		"BLOGO_FEED_URL":          "https://example.com/feed",
+		"BLOGO_FEED_TITLE":        "Example",
+		"BLOGO_POST_ID":           "1",
+		"BLOGO_POST_TITLE":        "Go 1.23 released",
+		"BLOGO_POST_URL":          "https://example.com/go",
+		"BLOGO_POST_DESCRIPTION":  "Iterators & more",
+		"BLOGO_POST_PUBLISHED_AT": "2024-05-01T10:00:00Z",
+	} {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+	if _, ok := env["BLOGO_POST_COUNT"]; ok {
+		t.Errorf("BLOGO_POST_COUNT set for a single post")
+	}
+	if env := readEnv(t, filepath.Join(dir, "3.env")); env["BLOGO_POST_ID"] != "3" {
+		t.Errorf("second run: BLOGO_POST_ID = %q, want 3", env["BLOGO_POST_ID"])
+	} else if _, ok := env["BLOGO_POST_PUBLISHED_AT"]; ok {
+		t.Errorf("BLOGO_POST_PUBLISHED_AT set for a post without a date")
+	}
+}
+
+// TestDispatchBatch checks that a batch hook runs once with the matching
+// posts as a JSON array.
+func TestDispatchBatch(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner([]Hook{{
+		Name:     "go-batch",
+		Command:  recordingCommand(t, dir),
+		Feeds:    []string{"https://example.com/feed"},
+		Keywords: []string{"go"},
+		Batch:    true,
+		Timeout:  10 * time.Second,
+	}}, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.Dispatch(context.Background(), testPosts())
+	r.Wait()
+
+	data, err := os.ReadFile(filepath.Join(dir, "batch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Post
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("stdin %s: %v", data, err)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Errorf("stdin %s, want posts 1 and 3", data)
+	}
+	env := readEnv(t, filepath.Join(dir, "batch.env"))
+	if env["BLOGO_POST_COUNT"] != "2" || env["BLOGO_FEED_URL"] != "https://example.com/feed" || env["BLOGO_HOOK"] != "go-batch" {
+		t.Errorf("env %v, want BLOGO_POST_COUNT=2 and the feed and hook", env)
+	}
+	if _, ok := env["BLOGO_POST_ID"]; ok {
+		t.Errorf("BLOGO_POST_ID set for a batch")
+	}
+}
+
+// TestDispatchNoMatch checks that hooks are not run for posts they filter
+// out, and that hooks without a command are dropped.
+func TestDispatchNoMatch(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner([]Hook{
+		{Name: "other-feed", Command: recordingCommand(t, dir), Feeds: []string{"https://other.example.com/feed"}, Timeout: 10 * time.Second},
+		{Name: "empty", Command: "  ", Timeout: 10 * time.Second},
+	}, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if len(r.hooks) != 1 {
+		t.Errorf("runner kept %d hooks, want 1", len(r.hooks))
+	}
+	r.Dispatch(context.Background(), testPosts())
+	r.Wait()
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("hook ran: %v, %v", entries, err)
+	}
+}
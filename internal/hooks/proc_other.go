@@ -0,0 +1,9 @@
+//go:build !unix
+
+package hooks
+
+import "os/exec"
+
+// killGroupOnCancel leaves cmd as is; only the shell itself is killed when
+// its context ends.
+func killGroupOnCancel(cmd *exec.Cmd) {}
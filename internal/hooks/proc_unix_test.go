@@ -0,0 +1,63 @@
+//go:build unix
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer collects log output written from the hook goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestDispatchTimeout checks that a hook running past its timeout is killed,
+// along with the commands its shell started.
+func TestDispatchTimeout(t *testing.T) {
+	dir := t.TempDir()
+	var logs lockedBuffer
+	r := NewRunner([]Hook{{
+		Name: "slow",
+		// The subshell would outlive sh if only sh were killed.
+		Command: `(sleep 1; touch '` + dir + `/child') & sleep 30; touch '` + dir + `/parent'`,
+		Timeout: 100 * time.Millisecond,
+	}}, 1, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	start := time.Now()
+	r.Dispatch(context.Background(), testPosts()[:1])
+	r.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hook ran for %v, want it killed after 100ms", elapsed)
+	}
+	if !strings.Contains(logs.String(), "hook timed out") {
+		t.Errorf("log %q, want a timeout logged", logs.String())
+	}
+
+	time.Sleep(1500 * time.Millisecond) // past the subshell's sleep
+	for _, name := range []string{"parent", "child"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("the %s kept running after the timeout", name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+
+	fetches := r.NewCounter("blogo_fetches_total", "Feed fetches.")
+	fetches.Inc()
+	fetches.Add(2.5)
+	r.NewCounter("blogo_unused_total", `Help with a \ and a
+line break.`)
+
+	errs := r.NewCounterVec("blogo_fetch_errors_total", "Failed fetches by reason.", "reason")
+	errs.Inc("timeout")
+	errs.Add("timeout", 2)
+	errs.Inc(`said "no"`)
+	errs.Inc(`C:\feeds` + "\nrss")
+
+	r.NewGaugeFunc("blogo_feeds", "Feeds in the database.", func() (float64, error) { return 42, nil })
+	r.NewGaugeFunc("blogo_broken", "Left out of the scrape.", func() (float64, error) { return 0, errors.New("database is locked") })
+
+	// Bounds given out of order; an observation equal to a bound falls in
+	// that bound's bucket.
+	h := r.NewHistogram("blogo_fetch_duration_seconds", "Fetch durations.", []float64{1, 0.25, 0.5})
+	for _, v := range []float64{0.125, 0.25, 0.75, 4} {
+		h.Observe(v)
+	}
+	r.NewHistogram("blogo_empty_seconds", "Nothing observed.", []float64{1})
+
+	want := `# HELP blogo_fetches_total Feed fetches.
+# TYPE blogo_fetches_total counter
+blogo_fetches_total 3.5
+# HELP blogo_unused_total Help with a \\ and a\nline break.
+# TYPE blogo_unused_total counter
+blogo_unused_total 0
+# HELP blogo_fetch_errors_total Failed fetches by reason.
+# TYPE blogo_fetch_errors_total counter
+blogo_fetch_errors_total{reason="C:\\feeds\nrss"} 1
+blogo_fetch_errors_total{reason="said \"no\""} 1
+blogo_fetch_errors_total{reason="timeout"} 3
+# HELP blogo_feeds Feeds in the database.
+# TYPE blogo_feeds gauge
+blogo_feeds 42
+# HELP blogo_fetch_duration_seconds Fetch durations.
+# TYPE blogo_fetch_duration_seconds histogram
+blogo_fetch_duration_seconds_bucket{le="0.25"} 2
+blogo_fetch_duration_seconds_bucket{le="0.5"} 2
+blogo_fetch_duration_seconds_bucket{le="1"} 3
+blogo_fetch_duration_seconds_bucket{le="+Inf"} 4
+blogo_fetch_duration_seconds_sum 5.125
+blogo_fetch_duration_seconds_count 4
+# HELP blogo_empty_seconds Nothing observed.
+# TYPE blogo_empty_seconds histogram
+blogo_empty_seconds_bucket{le="1"} 0
+blogo_empty_seconds_bucket{le="+Inf"} 0
+blogo_empty_seconds_sum 0
+blogo_empty_seconds_count 0
+`
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if rec.Body.String() != want {
+		t.Errorf("served:\n%s\nwant:\n%s", rec.Body.String(), want)
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	if got, want := ExponentialBuckets(0.25, 2, 4), []float64{0.25, 0.5, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("ExponentialBuckets(0.25, 2, 4) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// OPML is an OPML 2.0 subscription list.
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outline is either a feed (XMLURL set) or a folder of further outlines.
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Subscription is a single feed from an OPML file.
+type Subscription struct {
+	Title string
+	URL   string
+	Group string // Folder path, nested folders joined with "/"; "" at top level
+}
+
+// Parse reads an OPML document.
+func Parse(r io.Reader) (*OPML, error) {
+	doc := &OPML{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("parse opml: %w", err)
+	}
+	return doc, nil
+}
+
+// Subscriptions flattens the outline tree into its feeds, remembering the
+// folder each one was filed under.
+func (o *OPML) Subscriptions() []Subscription {
+	var out []Subscription
+	var walk func(outlines []Outline, group []string)
+	walk = func(outlines []Outline, group []string) {
+		for _, ol := range outlines {
+			title := ol.Title
+			if title == "" {
+				title = ol.Text
+			}
+			if ol.XMLURL != "" {
+				if title == "" {
+					title = ol.XMLURL
+				}
+				out = append(out, Subscription{
+					Title: title,
+					URL:   ol.XMLURL,
+					Group: strings.Join(group, "/"),
+				})
+			}
+			if len(ol.Outlines) > 0 {
+				walk(ol.Outlines, append(group[:len(group):len(group)], title))
+			}
+		}
+	}
+	walk(o.Body.Outlines, nil)
+	return out
+}
+
+// Write encodes subscriptions as an OPML 2.0 document. Groups become folder
+// outlines, nested along the "/" in their path, in order of first
+// appearance.
+func Write(w io.Writer, title string, subs []Subscription) error {
+	doc := OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, sub := range subs {
+		ol := Outline{Text: sub.Title, Title: sub.Title, Type: "rss", XMLURL: sub.URL}
+		var path []string
+		for _, name := range strings.Split(sub.Group, "/") {
+			if name != "" {
+				path = append(path, name)
+			}
+		}
+		doc.Body.Outlines = addToFolder(doc.Body.Outlines, path, ol)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("write opml: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// addToFolder files ol under the folder path within outlines, creating the
+// folders that don't exist yet.
+func addToFolder(outlines []Outline, path []string, ol Outline) []Outline {
+	if len(path) == 0 {
+		return append(outlines, ol)
+	}
+	for i := range outlines {
+		if outlines[i].XMLURL == "" && outlines[i].Text == path[0] {
+			outlines[i].Outlines = addToFolder(outlines[i].Outlines, path[1:], ol)
+			return outlines
+		}
+	}
+	folder := Outline{Text: path[0], Title: path[0]}
+	folder.Outlines = addToFolder(nil, path[1:], ol)
+	return append(outlines, folder)
+}
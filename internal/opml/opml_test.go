@@ -0,0 +1,75 @@
+package opml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const onboarding = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Team feeds</title></head>
+  <body>
+    <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+    <outline text="Engineering">
+      <outline text="Backend">
+        <outline text="Databases">
+          <outline text="SQLite News" type="rss" xmlUrl="https://sqlite.org/news.rss"/>
+        </outline>
+        <outline text="Postgres Weekly" type="rss" xmlUrl="https://postgresweekly.com/rss"/>
+      </outline>
+      <outline text="Frontend">
+        <outline text="CSS Tricks" type="rss" xmlUrl="https://css-tricks.com/feed/"/>
+      </outline>
+    </outline>
+  </body>
+</opml>`
+
+func TestSubscriptions(t *testing.T) {
+	doc, err := Parse(strings.NewReader(onboarding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subscription{
+		{"Go Blog", "https://go.dev/blog/feed.atom", ""},
+		{"SQLite News", "https://sqlite.org/news.rss", "Engineering/Backend/Databases"},
+		{"Postgres Weekly", "https://postgresweekly.com/rss", "Engineering/Backend"},
+		{"CSS Tricks", "https://css-tricks.com/feed/", "Engineering/Frontend"},
+	}
+	if got := doc.Subscriptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Subscriptions() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+// TestRoundTrip checks that exporting imported subscriptions keeps their
+// nested folders.
+func TestRoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(onboarding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := doc.Subscriptions()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "exported", subs); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("parse exported opml: %v\n%s", err, buf.String())
+	}
+	if got := again.Subscriptions(); !reflect.DeepEqual(got, subs) {
+		t.Errorf("after a round trip:\n%q\nwant\n%q", got, subs)
+	}
+
+	var folders []string
+	for _, ol := range again.Body.Outlines {
+		if ol.XMLURL == "" {
+			folders = append(folders, ol.Text)
+		}
+	}
+	if !reflect.DeepEqual(folders, []string{"Engineering"}) {
+		t.Errorf("top-level folders = %q, want only Engineering", folders)
+	}
+}
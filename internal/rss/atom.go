@@ -0,0 +1,79 @@
+package rss
+
+import "encoding/xml"
+
+// atomFeed is the subset of an Atom 1.0 document that blogo understands.
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle"`
+	Links     []Link      `xml:"link"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Links     []Link   `xml:"link"`
+	Summary   atomText `xml:"summary"`
+	Content   atomText `xml:"content"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+}
+
+// atomText holds an Atom text construct. Text and html content arrive as
+// character data, xhtml content as nested markup.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+// alternateLink returns the href of the first rel="alternate" link, which
+// is also the meaning of a link without a rel attribute.
+func alternateLink(links []Link) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// toRSS converts the Atom document into the RSS structures.
+func (a *atomFeed) toRSS() *RSSFeed {
+	feed := &RSSFeed{Channel: RSSChannel{
+		Title:       a.Title,
+		Links:       a.Links,
+		Link:        alternateLink(a.Links),
+		Description: a.Subtitle,
+		Generator:   a.Generator,
+	}}
+	for _, e := range a.Entries {
+		desc := e.Summary.String()
+		if desc == "" {
+			desc = e.Content.String()
+		}
+		pub := e.Published
+		if pub == "" {
+			pub = e.Updated
+		}
+		feed.Channel.Items = append(feed.Channel.Items, RSSItem{
+			Title:       e.Title,
+			Link:        alternateLink(e.Links),
+			Description: desc,
+			PubDate:     pub,
+			GUID:        e.ID,
+			Updated:     e.Updated,
+		})
+	}
+	return feed
+}
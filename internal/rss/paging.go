@@ -0,0 +1,49 @@
+package rss
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// NextPage returns the URL of the page that follows pageURL when walking a
+// feed's history, or "" if there is none.
+//
+// Paged and archived feeds (RFC 5005) are followed through their "next" and
+// "prev-archive" links. WordPress feeds, which do not advertise paging, are
+// walked with ?paged=N; page is the 1-based number of pageURL.
+func (f *RSSFeed) NextPage(pageURL string, page int) string {
+	for _, rel := range []string{"next", "prev-archive"} {
+		for _, l := range f.Channel.Links {
+			if l.Rel == rel && l.Href != "" {
+				return resolveURL(pageURL, l.Href)
+			}
+		}
+	}
+
+	if strings.Contains(strings.ToLower(f.Channel.Generator), "wordpress") {
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return ""
+		}
+		q := u.Query()
+		q.Set("paged", strconv.Itoa(page+1))
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return ""
+}
+
+// resolveURL resolves ref against base, returning ref unchanged if either
+// fails to parse.
+func resolveURL(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
@@ -0,0 +1,201 @@
+package rss
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsAgent is the product token robots.txt groups are matched against.
+const robotsAgent = "blogo"
+
+// robotsErrorTTL is how long an unreachable robots.txt keeps its host
+// disallowed before it is tried again.
+const robotsErrorTTL = 10 * time.Minute
+
+// maxRobotsSize caps how much of a robots.txt is read (RFC 9309 asks
+// crawlers to parse at least 500 KiB).
+const maxRobotsSize = 512 << 10
+
+// RobotsChecker decides whether URLs may be fetched according to their
+// host's robots.txt (RFC 9309). Files are cached per host for ttl.
+type RobotsChecker struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	cache map[string]robotsEntry // keyed by scheme://host
+}
+
+type robotsEntry struct {
+	rules   []robotsRule
+	expires time.Time
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+func NewRobotsChecker(ttl time.Duration) *RobotsChecker {
+	return &RobotsChecker{ttl: ttl, cache: make(map[string]robotsEntry)}
+}
+
+// Allowed reports whether blogo may fetch rawURL, and whether robots.txt
+// had to be requested to find out, so callers can space their next request
+// to the host.
+//
+// A missing robots.txt (4xx) allows everything. One that cannot be retrieved
+// (network error, 5xx) disallows the whole host until it can be. An error is
+// returned if rawURL is malformed or ctx ends while fetching robots.txt.
+func (c *RobotsChecker) Allowed(ctx context.Context, rawURL string) (allowed, fetched bool, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, false, err
+	}
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		entry, fetched = c.fetch(ctx, key), true
+		if err := ctx.Err(); err != nil {
+			return false, true, err // don't cache a disallow caused by shutdown
+		}
+		c.mu.Lock()
+		c.cache[key] = entry
+		c.mu.Unlock()
+	}
+	return robotsAllows(entry.rules, u.RequestURI()), fetched, nil
+}
+
+// fetch retrieves and parses the robots.txt at base.
+func (c *RobotsChecker) fetch(ctx context.Context, base string) robotsEntry {
+	disallowAll := robotsEntry{
+		rules:   []robotsRule{{allow: false, pattern: "/"}},
+		expires: time.Now().Add(robotsErrorTTL),
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/robots.txt", nil)
+	if err != nil {
+		return disallowAll
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := (&http.Client{Timeout: fetchTimeout}).Do(req)
+	if err != nil {
+		return disallowAll
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return disallowAll
+	case resp.StatusCode >= 400:
+		return robotsEntry{expires: time.Now().Add(c.ttl)}
+	case resp.StatusCode >= 300:
+		// The client already followed up to ten redirects.
+		return disallowAll
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return disallowAll
+	}
+	return robotsEntry{rules: parseRobots(body, robotsAgent), expires: time.Now().Add(c.ttl)}
+}
+
+// parseRobots returns the rules of the groups that apply to agent, falling
+// back to the "*" groups if none name it. Agent must be lower case; group
+// names are compared with it case-insensitively, as whole tokens, so
+// "blogobot" does not name blogo.
+func parseRobots(body []byte, agent string) []robotsRule {
+	var specific, wildcard []robotsRule
+	var agents []string
+	named := false   // whether any group is addressed to agent
+	inRules := false // whether the current group's user-agent lines ended
+
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			value = strings.ToLower(value)
+			agents = append(agents, value)
+			if value == agent {
+				named = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // "Disallow:" with no path allows everything
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, a := range agents {
+				switch {
+				case a == "*":
+					wildcard = append(wildcard, rule)
+				case a == agent:
+					specific = append(specific, rule)
+				}
+			}
+		}
+	}
+	// A group naming blogo replaces the "*" group, even if it has no rules.
+	if named {
+		return specific
+	}
+	return wildcard
+}
+
+// robotsAllows applies the most specific (longest) matching rule to path;
+// allow wins ties, and no matching rule means allowed.
+func robotsAllows(rules []robotsRule, path string) bool {
+	allowed, longest := true, -1
+	for _, r := range rules {
+		if !robotsMatch(r.pattern, path) {
+			continue
+		}
+		if n := len(r.pattern); n > longest || (n == longest && r.allow) {
+			allowed, longest = r.allow, n
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a robots.txt path pattern, where "*" matches any
+// sequence and a trailing "$" anchors the end of the path.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,36 @@
+package rss
+
+import "testing"
+
+func TestParseRobotsAgent(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		secret bool // whether /secret is allowed
+	}{
+		{"exact", "User-agent: blogo\nDisallow: /secret\n", false},
+		{"upper case", "User-agent: BLOGO\nDisallow: /secret\n", false},
+		{"mixed case", "user-agent: Blogo\ndisallow: /secret\n", false},
+		{"longer token", "User-agent: blogobot\nDisallow: /secret\n", true},
+		{"shorter token", "User-agent: blog\nDisallow: /secret\n", true},
+		{"wildcard", "User-agent: *\nDisallow: /secret\n", false},
+		{
+			"named group replaces wildcard",
+			"User-agent: *\nDisallow: /secret\n\nUser-agent: Blogo\nAllow: /\n",
+			true,
+		},
+		{
+			"other agent does not replace wildcard",
+			"User-agent: *\nDisallow: /secret\n\nUser-agent: blogobot\nAllow: /\n",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots([]byte(tt.body), robotsAgent)
+			if got := robotsAllows(rules, "/secret"); got != tt.secret {
+				t.Errorf("/secret allowed = %v, want %v (rules %v)", got, tt.secret, rules)
+			}
+		})
+	}
+}
@@ -2,11 +2,23 @@ package rss
 
 import (
 	"blogo/internal/utils"
+	"bytes"
+	"context"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// UserAgent identifies blogo to feed servers. Its product token, "blogo", is
+// what robots.txt rules are matched against.
+const UserAgent = "blogo (+https://github.com/RedSquirrelsNut/blogo)"
+
+// fetchTimeout bounds a single feed download, including reading the body.
+const fetchTimeout = 30 * time.Second
+
 type RSSFeed struct {
 	XMLName xml.Name   `xml:"rss"`
 	Channel RSSChannel `xml:"channel"`
@@ -14,27 +26,62 @@ type RSSFeed struct {
 
 type RSSChannel struct {
 	Title       string    `xml:"title"`
+	Links       []Link    `xml:"http://www.w3.org/2005/Atom link"` // must precede Link to claim <atom:link>
 	Link        string    `xml:"link"`
 	Description string    `xml:"description"`
+	Generator   string    `xml:"generator"`
 	Items       []RSSItem `xml:"item"`
 }
 
+// Link is an Atom link relation, used by feeds to point at other pages of
+// themselves (RFC 5005).
+type Link struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
 type RSSItem struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+	Updated     string `xml:"updated"` // e.g. <atom:updated>, empty if the feed never revises items
+}
+
+// Response is the raw result of fetching a feed document.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// StatusError is returned when a feed server answers with a non-2xx status.
+type StatusError struct {
+	Code       int
+	Status     string
+	RetryAfter time.Duration // From the Retry-After header of a 429 or 503, else 0
 }
 
-func FetchFeed(feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequest("GET", feedURL, nil)
+func (e *StatusError) Error() string {
+	return "unexpected HTTP status " + e.Status
+}
+
+// Fetch downloads a feed document without parsing it. The request is
+// abandoned when ctx ends.
+//
+// Non-2xx responses are returned as-is; use FetchFeed to treat them as errors.
+func Fetch(ctx context.Context, feedURL string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", `W/"blogo"`)
+	req.Header.Set("User-Agent", UserAgent)
 
-	client := &http.Client{}
+	// Without a timeout one unresponsive server would tie up a fetch
+	// worker indefinitely.
+	client := &http.Client{Timeout: fetchTimeout}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -45,10 +92,74 @@ func FetchFeed(feedURL string) (*RSSFeed, error) {
 	if err != nil {
 		return nil, err
 	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// FetchFeed downloads and parses a feed.
+//
+// Returns a *StatusError if the server does not answer with a 2xx status.
+func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	resp, err := Fetch(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Feed()
+}
+
+// Feed parses the fetched document.
+//
+// Returns a *StatusError if the server did not answer with a 2xx status.
+func (r *Response) Feed() (*RSSFeed, error) {
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		err := &StatusError{Code: r.StatusCode, Status: fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))}
+		if r.StatusCode == http.StatusTooManyRequests || r.StatusCode == http.StatusServiceUnavailable {
+			err.RetryAfter = parseRetryAfter(r.Header.Get("Retry-After"), time.Now())
+		}
+		return nil, err
+	}
+	return ParseFeed(r.Body)
+}
+
+// parseRetryAfter reads a Retry-After header given either as a number of
+// seconds or as an HTTP date. Returns 0 if the header is missing, malformed
+// or already in the past.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// ParseFeed decodes an RSS 2.0 or Atom 1.0 document.
+//
+// Atom documents are converted into the RSS structures so callers only
+// deal with one shape. Titles and descriptions are stripped of HTML.
+func ParseFeed(body []byte) (*RSSFeed, error) {
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, err
+	}
 
 	feed := &RSSFeed{}
-	if unmarshalErr := xml.Unmarshal(body, feed); unmarshalErr != nil {
-		return nil, unmarshalErr
+	switch root {
+	case "rss":
+		if err := xml.Unmarshal(body, feed); err != nil {
+			return nil, err
+		}
+	case "feed":
+		atom := &atomFeed{}
+		if err := xml.Unmarshal(body, atom); err != nil {
+			return nil, err
+		}
+		feed = atom.toRSS()
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", root)
 	}
 
 	feed.Channel.Title = utils.StripHTML(feed.Channel.Title)
@@ -61,3 +172,17 @@ func FetchFeed(feedURL string) (*RSSFeed, error) {
 
 	return feed, nil
 }
+
+// rootElement returns the local name of the document's first element.
+func rootElement(body []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("find root element: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package rss
+
+import (
+	"blogo/internal/utils"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Report describes the problems found in a feed document by Validate.
+type Report struct {
+	Format       string            // Detected format, e.g. "RSS 2.0"
+	ParseError   error             // Set if the feed could not be parsed
+	Title        string            // Channel title
+	Items        int               // Number of items
+	MissingLink  []int             // 1-based item numbers without a link
+	MissingGUID  []int             // 1-based item numbers without a GUID/id
+	MissingDate  []int             // 1-based item numbers without a date
+	BadDates     []ItemValue       // Dates utils.ParsePubDate rejects
+	RelativeURLs []ItemValue       // Links that are not absolute URLs
+	Duplicates   map[string][]int  // Links shared by several items
+	Encoding     []string          // Character encoding problems
+	Caching      map[string]string // HTTP caching headers, nil for local files
+}
+
+// ItemValue points at a value of a specific item.
+type ItemValue struct {
+	Item  int // 1-based item number; 0 for the channel itself
+	Value string
+}
+
+// CachingHeaders are the response headers that let clients poll cheaply.
+var CachingHeaders = []string{"ETag", "Last-Modified", "Cache-Control", "Expires"}
+
+// Problems returns the number of problems in the report. Missing caching
+// headers are advisory and not counted.
+func (r *Report) Problems() int {
+	n := len(r.MissingLink) + len(r.MissingGUID) + len(r.MissingDate) +
+		len(r.BadDates) + len(r.RelativeURLs) + len(r.Duplicates) + len(r.Encoding)
+	if r.ParseError != nil {
+		n++
+	}
+	return n
+}
+
+// Validate parses a feed document with the same parser the aggregator uses
+// and reports what is wrong with it. header holds the HTTP response headers
+// and may be nil for documents read from disk.
+func Validate(body []byte, header http.Header) *Report {
+	r := &Report{
+		Format:     detectFormat(body),
+		Duplicates: make(map[string][]int),
+		Encoding:   checkEncoding(body, header),
+	}
+	if header != nil {
+		r.Caching = make(map[string]string)
+		for _, h := range CachingHeaders {
+			if v := header.Get(h); v != "" {
+				r.Caching[h] = v
+			}
+		}
+	}
+
+	feed, err := ParseFeed(body)
+	if err != nil {
+		r.ParseError = err
+		return r
+	}
+	r.Title = feed.Channel.Title
+	r.Items = len(feed.Channel.Items)
+	if feed.Channel.Link != "" && !isAbsURL(feed.Channel.Link) {
+		r.RelativeURLs = append(r.RelativeURLs, ItemValue{Item: 0, Value: feed.Channel.Link})
+	}
+
+	seen := make(map[string]int)
+	for i, item := range feed.Channel.Items {
+		n := i + 1
+		if item.Link == "" {
+			r.MissingLink = append(r.MissingLink, n)
+		} else {
+			if !isAbsURL(item.Link) {
+				r.RelativeURLs = append(r.RelativeURLs, ItemValue{Item: n, Value: item.Link})
+			}
+			if first, ok := seen[item.Link]; ok {
+				if len(r.Duplicates[item.Link]) == 0 {
+					r.Duplicates[item.Link] = []int{first}
+				}
+				r.Duplicates[item.Link] = append(r.Duplicates[item.Link], n)
+			} else {
+				seen[item.Link] = n
+			}
+		}
+		if item.GUID == "" {
+			r.MissingGUID = append(r.MissingGUID, n)
+		}
+		if item.PubDate == "" {
+			r.MissingDate = append(r.MissingDate, n)
+		} else if _, err := utils.ParsePubDate(item.PubDate); err != nil {
+			r.BadDates = append(r.BadDates, ItemValue{Item: n, Value: item.PubDate})
+		}
+		if item.Updated != "" {
+			if _, err := utils.ParsePubDate(item.Updated); err != nil {
+				r.BadDates = append(r.BadDates, ItemValue{Item: n, Value: item.Updated})
+			}
+		}
+	}
+	return r
+}
+
+// detectFormat names the feed format from the document's root element.
+func detectFormat(body []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "unknown (no XML root element)"
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case start.Name.Local == "rss":
+			for _, a := range start.Attr {
+				if a.Name.Local == "version" {
+					return "RSS " + a.Value
+				}
+			}
+			return "RSS (no version)"
+		case start.Name.Local == "feed" && start.Name.Space == "http://www.w3.org/2005/Atom":
+			return "Atom 1.0"
+		case start.Name.Local == "feed":
+			return "Atom (missing Atom namespace)"
+		case start.Name.Local == "RDF":
+			return "RSS 1.0 (RDF)"
+		default:
+			return "unknown (<" + start.Name.Local + ">)"
+		}
+	}
+}
+
+var xmlEncodingRe = regexp.MustCompile(`^\s*<\?xml[^>]*encoding=["']([^"']+)["']`)
+
+// checkEncoding reports character encoding problems the parser would trip
+// over or silently mangle.
+func checkEncoding(body []byte, header http.Header) []string {
+	var out []string
+	declared := "utf-8"
+	if m := xmlEncodingRe.FindSubmatch(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))); m != nil {
+		declared = normalizeCharset(string(m[1]))
+	}
+	if declared != "utf-8" {
+		out = append(out, "XML declaration specifies "+declared+"; only UTF-8 is supported")
+	}
+	if !utf8.Valid(body) {
+		out = append(out, "document contains bytes that are not valid UTF-8")
+	}
+	if header != nil {
+		if _, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+			if cs := normalizeCharset(params["charset"]); cs != "" && cs != declared {
+				out = append(out, "Content-Type charset "+cs+" disagrees with the document's "+declared)
+			}
+		}
+	}
+	return out
+}
+
+func normalizeCharset(cs string) string {
+	cs = strings.ToLower(strings.TrimSpace(cs))
+	if cs == "utf8" {
+		return "utf-8"
+	}
+	return cs
+}
+
+func isAbsURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.IsAbs()
+}
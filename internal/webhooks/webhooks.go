@@ -0,0 +1,158 @@
+// Package webhooks formats, signs and sends new-post notifications to
+// webhook receivers.
+package webhooks
+
+import (
+	"blogo/internal/rss"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Payload formats.
+const (
+	FormatJSON    = "json"    // blogo's own payload
+	FormatSlack   = "slack"   // Slack incoming webhooks
+	FormatDiscord = "discord" // Discord channel webhooks
+	FormatMatrix  = "matrix"  // matrix-hookshot generic webhooks
+)
+
+// Formats lists the supported payload formats.
+var Formats = []string{FormatJSON, FormatSlack, FormatDiscord, FormatMatrix}
+
+// Event is the value of the X-Blogo-Event header, and of "event" in JSON
+// payloads.
+const Event = "post.created"
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed
+// with the webhook's secret, as "sha256=<hex>".
+const SignatureHeader = "X-Blogo-Signature"
+
+// Target is where a message is sent and how.
+type Target struct {
+	URL    string
+	Format string
+	Secret string
+}
+
+// Message announces one new post.
+type Message struct {
+	DeliveryID int64 `json:"delivery_id"`
+	Feed       Feed  `json:"feed"`
+	Post       Post  `json:"post"`
+}
+
+type Feed struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type Post struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// Payload renders m as a request body in the given format.
+func Payload(format string, m Message) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return marshal(struct {
+			Event string `json:"event"`
+			Message
+		}{Event, m})
+	case FormatSlack:
+		esc := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+		return marshal(map[string]string{
+			"text": fmt.Sprintf("New post in %s: <%s|%s>", esc(m.Feed.Name), m.Post.URL, esc(m.Post.Title)),
+		})
+	case FormatDiscord:
+		esc := strings.NewReplacer("[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`).Replace
+		return marshal(map[string]string{
+			"username": "blogo",
+			"content":  truncate(fmt.Sprintf("New post in **%s**: [%s](<%s>)", esc(m.Feed.Name), esc(m.Post.Title), m.Post.URL), 2000),
+		})
+	case FormatMatrix:
+		return marshal(map[string]string{
+			"username": "blogo",
+			"text":     fmt.Sprintf("New post in %s: %s %s", m.Feed.Name, m.Post.Title, m.Post.URL),
+			"html": fmt.Sprintf(`New post in <b>%s</b>: <a href="%s">%s</a>`,
+				html.EscapeString(m.Feed.Name), html.EscapeString(m.Post.URL), html.EscapeString(m.Post.Title)),
+		})
+	}
+	return nil, fmt.Errorf("unknown webhook format %q", format)
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts m to the target. Any 2xx response counts as delivered.
+//
+// Returns the HTTP status (0 if no response was received) and an error if
+// the message was not delivered.
+func Send(ctx context.Context, client *http.Client, t Target, m Message) (int, error) {
+	body, err := Payload(t.Format, m)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", rss.UserAgent)
+	req.Header.Set("X-Blogo-Event", Event)
+	req.Header.Set("X-Blogo-Delivery", fmt.Sprint(m.DeliveryID))
+	if t.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(t.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(snippet))
+		if msg == "" {
+			return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+		}
+		return resp.StatusCode, fmt.Errorf("receiver returned %s: %s", resp.Status, msg)
+	}
+	return resp.StatusCode, nil
+}
+
+// marshal encodes v as JSON, leaving <, > and & alone since several formats
+// use them as markup.
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
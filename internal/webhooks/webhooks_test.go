@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMessage = Message{
+	DeliveryID: 7,
+	Feed:       Feed{Name: "Go <Blog>", URL: "https://go.dev/blog/feed.atom"},
+	Post: Post{
+		ID:    42,
+		Title: "Range over *func* [types]",
+		URL:   "https://go.dev/blog/range-functions",
+	},
+}
+
+// received is a request as seen by the receiver.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts a receiver that answers every request with status and
+// sends what it got on the returned channel.
+func newReceiver(t *testing.T, status int) (*httptest.Server, <-chan received) {
+	t.Helper()
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header, body}
+		w.WriteHeader(status)
+		if status >= 300 {
+			io.WriteString(w, "try later\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestSendSigned(t *testing.T) {
+	srv, got := newReceiver(t, http.StatusNoContent)
+	const secret = "s3cret"
+	status, err := Send(context.Background(), srv.Client(), Target{URL: srv.URL, Format: FormatJSON, Secret: secret}, testMessage)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v; want 204", status, err)
+	}
+	r := <-got
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(r.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := r.header.Get(SignatureHeader); sig != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, sig, want)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":     "application/json",
+		"X-Blogo-Event":    Event,
+		"X-Blogo-Delivery": "7",
+	} {
+		if v := r.header.Get(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+}
+
+func TestSendUnsigned(t *testing.T) {
+	srv, got := newReceiver(t, http.StatusOK)
+	if _, err := Send(context.Background(), srv.Client(), Target{URL: srv.URL, Format: FormatJSON}, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if sig := (<-got).header.Get(SignatureHeader); sig != "" {
+		t.Errorf("%s = %q without a secret, want none", SignatureHeader, sig)
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	srv, _ := newReceiver(t, http.StatusServiceUnavailable)
+	status, err := Send(context.Background(), srv.Client(), Target{URL: srv.URL, Format: FormatJSON}, testMessage)
+	if status != http.StatusServiceUnavailable || err == nil {
+		t.Fatalf("Send = %d, %v; want 503 and an error", status, err)
+	}
+	if want := "receiver returned 503 Service Unavailable: try later"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	published := time.Date(2024, 8, 20, 12, 0, 0, 0, time.UTC)
+	m := testMessage
+	m.Post.PublishedAt = &published
+
+	tests := []struct {
+		format string
+		want   map[string]any
+	}{
+		{FormatJSON, map[string]any{
+			"event":       Event,
+			"delivery_id": 7.0,
+			"feed":        map[string]any{"name": "Go <Blog>", "url": "https://go.dev/blog/feed.atom"},
+			"post": map[string]any{
+				"id":           42.0,
+				"title":        "Range over *func* [types]",
+				"url":          "https://go.dev/blog/range-functions",
+				"published_at": "2024-08-20T12:00:00Z",
+			},
+		}},
+		{FormatSlack, map[string]any{
+			"text": "New post in Go &lt;Blog&gt;: <https://go.dev/blog/range-functions|Range over *func* [types]>",
+		}},
+		{FormatDiscord, map[string]any{
+			"username": "blogo",
+			"content":  `New post in **Go <Blog>**: [Range over \*func\* \[types\]](<https://go.dev/blog/range-functions>)`,
+		}},
+		{FormatMatrix, map[string]any{
+			"username": "blogo",
+			"text":     "New post in Go <Blog>: Range over *func* [types] https://go.dev/blog/range-functions",
+			"html":     `New post in <b>Go &lt;Blog&gt;</b>: <a href="https://go.dev/blog/range-functions">Range over *func* [types]</a>`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			body, err := Payload(tt.format, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("body is not JSON: %v\n%s", err, body)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("payload:\ngot  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Payload("teams", m); err == nil || !strings.Contains(err.Error(), "unknown webhook format") {
+		t.Errorf("unknown format: err = %v", err)
+	}
+}
@@ -4,10 +4,19 @@ import (
 	"blogo/internal/cli"
 	"blogo/internal/config"
 	"blogo/internal/database"
+	"blogo/internal/logging"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -15,58 +24,195 @@ import (
 type App struct {
 	Cfg *config.Config
 	DB  *sql.DB
+	Log *slog.Logger
 }
 
-func Setup() *App {
+// globalOptions are the options given before the command name.
+type globalOptions struct {
+	logLevel  string
+	logFormat string
+	db        string
+}
+
+// parseGlobalOptions splits leading --name[=value] options from the
+// command and its arguments.
+func parseGlobalOptions(args []string) (globalOptions, []string, error) {
+	var opts globalOptions
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return opts, nil, fmt.Errorf("option --%s needs a value", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		switch name {
+		case "log-level":
+			opts.logLevel = value
+		case "log-format":
+			opts.logFormat = value
+		case "db":
+			opts.db = value
+		default:
+			return opts, nil, fmt.Errorf("unknown option --%s", name)
+		}
+	}
+	return opts, args, nil
+}
+
+// Setup reads the config and opens the database, bringing its schema up to
+// date unless migrate is false.
+func Setup(opts globalOptions, migrate bool) (*App, error) {
 	cfg, err := config.Read()
 	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	db, err := sql.Open("sqlite3", "./blogo.db")
+	level, format := cfg.LogLevel, cfg.LogFormat
+	if opts.logLevel != "" {
+		level = opts.logLevel
+	}
+	if opts.logFormat != "" {
+		format = opts.logFormat
+	}
+	logger, err := logging.New(os.Stderr, level, format)
 	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+		return nil, err
 	}
+	slog.SetDefault(logger)
 
-	if err := database.CreateUserTable(db); err != nil {
-		log.Fatal(err)
+	path, err := dbPath(cfg, opts, logger)
+	if err != nil {
+		return nil, err
 	}
-	if err := database.CreateFeedsTable(db); err != nil {
-		log.Fatal(err)
+	db, err := sql.Open("sqlite3", dsn(path, cfg))
+	if err != nil {
+		return nil, err
 	}
-	if err := database.CreateFeedFollowsTable(db); err != nil {
-		log.Fatal(err)
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxOpenConns)
+
+	if migrate {
+		applied, err := database.Migrate(db)
+		if errors.Is(err, database.ErrSchemaTooNew) {
+			db.Close()
+			return nil, fmt.Errorf("%w; upgrade blogo, or revert the newer migrations with the blogo that applied them", err)
+		}
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		for _, m := range applied {
+			logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+	}
+	return &App{Cfg: cfg, DB: db, Log: logger}, nil
+}
+
+// dbPath returns where the database is: --db, else $BLOGO_DB, else db_path
+// from the config. Its directory is created if missing.
+func dbPath(cfg *config.Config, opts globalOptions, logger *slog.Logger) (string, error) {
+	path, fromConfig := cfg.DBPath, true
+	if env := os.Getenv("BLOGO_DB"); env != "" {
+		path, fromConfig = env, false
+	}
+	if opts.db != "" {
+		path, fromConfig = opts.db, false
+	}
+	path, err := config.ExpandPath(path)
+	if err != nil {
+		return "", err
 	}
-	if err := database.CreatePostsTable(db); err != nil {
-		log.Fatal(err)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("create database directory: %w", err)
 	}
-	return &App{Cfg: cfg, DB: db}
+
+	// Older versions always used blogo.db in the working directory.
+	if _, err := os.Stat(path); fromConfig && errors.Is(err, os.ErrNotExist) {
+		if old, err := filepath.Abs("blogo.db"); err == nil && old != path {
+			if _, err := os.Stat(old); err == nil {
+				logger.Warn("creating a new database, but found one from an older blogo; move it to keep its data",
+					"path", path, "found", old)
+			}
+		}
+	}
+	logger.Debug("opening database", "path", path)
+	return path, nil
+}
+
+// dsn returns the go-sqlite3 data source name for the database at path.
+// Every connection gets the same settings:
+//   - incremental auto-vacuum, so pruning can return space (new databases only)
+//   - the configured journal mode, WAL by default, so readers don't block on
+//     the aggregator's writes
+//   - a busy timeout, so writers wait for each other instead of failing with
+//     "database is locked"
+//   - immediate transactions, which take the write lock up front, since one
+//     that reads first can't wait for it later
+//   - enforced foreign keys, for ON DELETE CASCADE
+func dsn(path string, cfg *config.Config) string {
+	params := url.Values{}
+	params.Set("_auto_vacuum", "incremental")
+	params.Set("_journal_mode", strings.ToUpper(cfg.DBJournalMode))
+	params.Set("_busy_timeout", strconv.FormatInt(cfg.DBBusyTimeout.Milliseconds(), 10))
+	params.Set("_txlock", "immediate")
+	params.Set("_foreign_keys", "on")
+	return path + "?" + params.Encode()
 }
 
 func (app *App) Close() {
 	app.DB.Close()
 }
 
-func (app *App) Run() {
-	s := cli.State{Cfg: app.Cfg, DB: app.DB}
+func (app *App) Run(ctx context.Context, args []string) error {
+	s := cli.State{Cfg: app.Cfg, DB: app.DB, Ctx: ctx, Log: app.Log}
 	c := cli.Commands{List: make(cli.CommandMap)}
 	cli.RegisterAllCommands(&c)
-	args := os.Args[1:]
 	if len(args) < 1 {
-		fmt.Println("Usage: blogo <some-arg>")
-		os.Exit(1)
+		return fmt.Errorf("Usage: blogo [--db path] [--log-level level] [--log-format text|json] <some-arg>")
 	}
 
 	com := cli.Command{Name: args[0], Args: args[1:]}
-	if err := c.Run(&s, com); err != nil {
-		log.Fatalf("Error: %v", err)
-	}
+	return c.Run(&s, com)
+}
+
+// shutdownContext returns a context that is cancelled by the first SIGINT or
+// SIGTERM, letting commands finish what they are writing. A second signal
+// exits immediately.
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		slog.Info("shutting down, repeat to force", "signal", sig.String())
+		cancel()
+		<-sigs
+		slog.Warn("forced exit")
+		os.Exit(1)
+	}()
+	return ctx
 }
 
 func main() {
-	app := Setup()
-	defer app.Close()
-	app.Run()
+	opts, args, err := parseGlobalOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	ctx := shutdownContext()
+	// The migrate command manages the schema itself, and must be able to
+	// inspect a database this binary cannot use.
+	app, err := Setup(opts, len(args) == 0 || args[0] != "migrate")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = app.Run(ctx, args)
+	app.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }